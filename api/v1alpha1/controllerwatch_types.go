@@ -17,21 +17,72 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type CRDInstallationStatus string
 type ControllerInstallationStatus string
+type StorageVersionMigrationState string
+
+// Hoisted returns true if the controller is installed, or is going to be installed
+func (s ControllerInstallationStatus) Hoisted() bool {
+	return s == ControllerInstallationStatusPending || s == ControllerInstallationStatusInstalling || s == ControllerInstallationStatusInstalled
+}
 
 const (
 	CRDInstallationStatusInvalidHelmChartValues CRDInstallationStatus        = "InvalidHelmChartValues"
 	CRDInstallationStatusHelmChartFailed        CRDInstallationStatus        = "HelmChartFailedToRender"
 	CRDInstallationStatusNoCRDsFound            CRDInstallationStatus        = "NoCRDsFoundInHelmChart"
+	CRDInstallationStatusNotAllowed             CRDInstallationStatus        = "CRDsNotAllowed"
+	CRDInstallationStatusUpgradeBlocked         CRDInstallationStatus        = "UpgradeBlocked"
 	CRDInstallationStatusInstalled              CRDInstallationStatus        = "Installed"
 	ControllerInstallationStatusPending         ControllerInstallationStatus = "Pending"
+	ControllerInstallationStatusInstalling      ControllerInstallationStatus = "Installing"
 	ControllerInstallationStatusInstallFailed   ControllerInstallationStatus = "InstallFailed"
 	ControllerInstallationStatusInstalled       ControllerInstallationStatus = "Installed"
+	ControllerInstallationStatusSleeping        ControllerInstallationStatus = "Sleeping"
+	StorageVersionMigrationRunning              StorageVersionMigrationState = "Running"
+	StorageVersionMigrationCompleted            StorageVersionMigrationState = "Completed"
+	StorageVersionMigrationFailed               StorageVersionMigrationState = "Failed"
+	// StorageVersionMigrationWaitingForController is used when the CRD uses a conversion webhook which is most likely
+	// served by the controller, so the migration can only run while the controller is installed
+	StorageVersionMigrationWaitingForController StorageVersionMigrationState = "WaitingForController"
+
+	// IgnoreAnnotation can be set to "true" on a custom resource so that it never triggers the installation of a controller
+	IgnoreAnnotation = "kubehoist.io/ignore"
+	// HoistAnnotation can be set to "now" on a ControllerWatch to install (or reinstall) the controller immediately
+	HoistAnnotation = "kubehoist.io/hoist"
+	// SleepAnnotation can be set to "now" on a ControllerWatch to uninstall the controller immediately.
+	// The controller will be installed again the next time one of its custom resources is used
+	SleepAnnotation = "kubehoist.io/sleep"
+	// ManualRequestValue is the only accepted value for the HoistAnnotation and SleepAnnotation
+	ManualRequestValue = "now"
+
+	// ConditionDependenciesReady is true when all controllers listed in dependsOn are installed
+	ConditionDependenciesReady = "DependenciesReady"
+
+	ReasonDependenciesReady      = "DependenciesReady"
+	ReasonWaitingForDependencies = "WaitingForDependencies"
+	ReasonDependencyNotFound     = "DependencyNotFound"
+	ReasonDependencyFailed       = "DependencyFailed"
+	ReasonDependencyCycle        = "DependencyCycle"
+
+	// ConditionCRDUpgradeSafe is false when applying the CRDs of the chart over the installed CRDs is unsafe
+	ConditionCRDUpgradeSafe = "CRDUpgradeSafe"
+
+	ReasonCRDUpgradeSafe            = "Safe"
+	ReasonStoredVersionRemoved      = "StoredVersionRemoved"
+	ReasonIncompatibleSchemaChanges = "IncompatibleSchemaChanges"
+
+	// ConditionBudgetExceeded is true while the controller is queued because installing it would exceed the hoist budget
+	ConditionBudgetExceeded = "BudgetExceeded"
+
+	ReasonWithinBudget        = "WithinBudget"
+	ReasonEvictingControllers = "EvictingControllers"
+	ReasonBudgetExceeded      = "BudgetExceeded"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -44,6 +95,155 @@ type ControllerWatchSpec struct {
 
 	// The helm install options where the CRD and controller to install and watch are defined
 	HelmControllerSpec HelmInstallSpec `json:"helmSpec,omitempty"`
+
+	// ServiceAccountName is the name of a service account in the helm install namespace which is impersonated when
+	// installing the chart, so that the controller is installed with only the permissions granted to that service account.
+	// If unset, the chart is installed with the permissions of kubehoist itself. Required for a NamespacedControllerWatch
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Trigger restricts which custom resources trigger the installation of the controller.
+	// By default, any custom resource of an installed CRD triggers the installation
+	// +optional
+	Trigger TriggerSpec `json:"trigger,omitempty"`
+
+	// Schedule defines time windows where the controller is kept installed or uninstalled, regardless of custom resource usage
+	// +optional
+	Schedule *ScheduleSpec `json:"schedule,omitempty"`
+
+	// DependsOn is a list of names of other ControllerWatches (or NamespacedControllerWatches in the same namespace) whose
+	// controllers must be installed before this controller is installed. They are hoisted automatically when this
+	// controller is hoisted
+	// +optional
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// Priority protects the controller from being put to sleep to make room in the hoist budget. Idle controllers are
+	// only evicted for a controller with the same or a higher priority, lowest priority first
+	// The priority of a NamespacedControllerWatch is capped at 0, and it only evicts controllers of its own namespace
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+
+	// CRDSource optionally overrides where the CRDs are extracted from, i.e. for projects which ship their CRDs in a
+	// dedicated chart. Any fields which are not set default to the values in helmSpec
+	// +optional
+	CRDSource *CRDSourceSpec `json:"crdSource,omitempty"`
+
+	// MigrateStorageVersions opts in to rewriting the stored objects of the installed CRDs when their storage version
+	// changes, after which the old versions are removed from the status.storedVersions of the CRD
+	// +optional
+	MigrateStorageVersions bool `json:"migrateStorageVersions,omitempty"`
+
+	// DryRun if true only plans what installing the chart would do, and writes the plan to status.plan without changing
+	// anything in the cluster. Setting it back to false executes the plan: the CRDs are applied, and the controller is
+	// installed as usual once it is triggered
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// CRDSourceSpec defines the chart which the CRDs are extracted from
+type CRDSourceSpec struct {
+	// The name [location] of the chart to extract the CRDs from
+	// +optional
+	Chart string `json:"chart,omitempty"`
+	// The version of the chart to extract the CRDs from
+	// +optional
+	Version string `json:"version,omitempty"`
+	// Optional helm values used to render the CRD chart instead of the helmSpec values. Should be a valid yaml or json string
+	// +optional
+	Values string `json:"values,omitempty"`
+}
+
+// ScheduleSpec defines time windows where the controller is kept warm (installed) or hibernating (uninstalled).
+// If a warm and a hibernate window overlap, the hibernate window takes precedence
+type ScheduleSpec struct {
+	// TimeZone is the IANA time zone name the window start schedules are evaluated in. Defaults to UTC
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+	// WarmWindows are windows where the controller is installed, even if none of its custom resources are used
+	// +optional
+	WarmWindows []ScheduleWindow `json:"warmWindows,omitempty"`
+	// HibernateWindows are windows where the controller is uninstalled, even if its custom resources are used
+	// +optional
+	HibernateWindows []ScheduleWindow `json:"hibernateWindows,omitempty"`
+}
+
+// ScheduleWindow is a recurring time window
+type ScheduleWindow struct {
+	// Start is a standard cron expression for when the window starts, i.e. "0 8 * * 1-5"
+	Start string `json:"start"`
+	// Duration is how long the window lasts after it starts, i.e. "10h"
+	Duration metav1.Duration `json:"duration"`
+}
+
+// TriggerSpec defines which custom resources trigger the installation of the controller.
+// Custom resources with the kubehoist.io/ignore: "true" annotation never trigger the installation
+type TriggerSpec struct {
+	// NamespaceSelector only allows custom resources in namespaces matching this selector to trigger the installation.
+	// Cluster scoped custom resources are not affected by this selector
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// LabelSelector only allows custom resources with labels matching this selector to trigger the installation
+	// +optional
+	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+	// Kinds restricts which of the installed CRD kinds trigger the installation. CRDs of other kinds are still installed,
+	// but are not watched
+	// +optional
+	Kinds TriggerKinds `json:"kinds,omitempty"`
+	// MinCount is how many custom resources need to exist before the installation is triggered. Custom resources of
+	// all trigger kinds are counted, but only if they pass the other trigger options
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinCount int32 `json:"minCount,omitempty"`
+	// MinAge is how long a custom resource needs to exist before it triggers the installation, so that custom
+	// resources which are deleted again right away (i.e. by tests) don't hoist the controller
+	// +optional
+	MinAge *metav1.Duration `json:"minAge,omitempty"`
+	// IgnoreReleaseOwned ignores custom resources which belong to the helm release of the controller itself, such as
+	// default resources or hooks of the chart
+	// +optional
+	IgnoreReleaseOwned bool `json:"ignoreReleaseOwned,omitempty"`
+	// IgnoreDeletes ignores custom resources which are being deleted. By default they trigger the installation, so that
+	// the controller can clean up after them and remove its finalizers
+	// +optional
+	IgnoreDeletes bool `json:"ignoreDeletes,omitempty"`
+}
+
+// TriggerKinds selects CRD kinds by API group and kind.
+// If Include is set, only matching kinds are selected. Kinds matching Exclude are never selected
+type TriggerKinds struct {
+	// +optional
+	Include []GroupKind `json:"include,omitempty"`
+	// +optional
+	Exclude []GroupKind `json:"exclude,omitempty"`
+}
+
+// GroupKind matches CRD kinds by API group and kind. If kind is empty, all kinds in the group are matched
+type GroupKind struct {
+	Group string `json:"group"`
+	// +optional
+	Kind string `json:"kind,omitempty"`
+}
+
+func (g GroupKind) Matches(gvk GroupVersionKind) bool {
+	return g.Group == gvk.Group && (g.Kind == "" || g.Kind == gvk.Kind)
+}
+
+// Selects returns true if the given kind should trigger the installation
+func (t TriggerKinds) Selects(gvk GroupVersionKind) bool {
+	for _, exclude := range t.Exclude {
+		if exclude.Matches(gvk) {
+			return false
+		}
+	}
+	if len(t.Include) == 0 {
+		return true
+	}
+	for _, include := range t.Include {
+		if include.Matches(gvk) {
+			return true
+		}
+	}
+	return false
 }
 
 type HelmInstallSpec struct {
@@ -59,9 +259,99 @@ type HelmInstallSpec struct {
 	// Optional helm values to pass to the chart. Should be a valid yaml or json string
 	// +optional
 	Values string `json:"values,omitempty"`
+	// Optional helm values which are merged over the values only when rendering the chart to extract its CRDs,
+	// i.e. to enable CRDs which are disabled for the real install. Should be a valid yaml or json string
+	// +optional
+	CRDValues string `json:"crdValues,omitempty"`
 	// CreateNamespace if true will create the namespace if it does not exist
 	// +optional
 	CreateNamespace *bool `json:"createNamespace,omitempty"`
+	// PostRenderer patches the rendered manifests of the chart before they are installed or upgraded, i.e. to add
+	// settings which the chart doesn't expose as values
+	// +optional
+	PostRenderer *PostRendererSpec `json:"postRenderer,omitempty"`
+	// Install configures the helm install of the chart when the release doesn't exist yet
+	// +optional
+	Install *HelmActionSpec `json:"install,omitempty"`
+	// Upgrade configures the helm upgrade of the chart when the release already exists
+	// +optional
+	Upgrade *HelmActionSpec `json:"upgrade,omitempty"`
+}
+
+// HelmActionSpec configures a helm install or upgrade
+type HelmActionSpec struct {
+	// Timeout is how long to wait for the install or upgrade. Defaults to 10m
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// Wait for the resources of the chart to be ready. Defaults to true
+	// +optional
+	Wait *bool `json:"wait,omitempty"`
+	// WaitForJobs waits for the jobs of the chart to complete as well, if wait is set
+	// +optional
+	WaitForJobs bool `json:"waitForJobs,omitempty"`
+	// Atomic rolls back a failed upgrade, or uninstalls a failed install
+	// +optional
+	Atomic bool `json:"atomic,omitempty"`
+	// DisableHooks skips running the hooks of the chart
+	// +optional
+	DisableHooks bool `json:"disableHooks,omitempty"`
+	// SkipCRDs skips installing the CRDs in the crds directory of the chart, since kubehoist already applied them
+	// +optional
+	SkipCRDs bool `json:"skipCRDs,omitempty"`
+	// MaxHistory limits the number of revisions kept for the release. Only used for upgrades. Defaults to unlimited
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxHistory int32 `json:"maxHistory,omitempty"`
+}
+
+// PostRendererSpec defines the patches applied to the rendered manifests of a chart.
+// Image rewrites are applied first, then strategic merge patches, then JSON 6902 patches
+type PostRendererSpec struct {
+	// StrategicMergePatches are strategic merge patches, i.e. to add nodeSelectors or tolerations to a Deployment.
+	// Kinds without a strategic merge patch schema, such as custom resources, are patched with a JSON merge patch instead
+	// +optional
+	StrategicMergePatches []ManifestPatch `json:"strategicMergePatches,omitempty"`
+	// JSON6902Patches are lists of JSON patch operations
+	// +optional
+	JSON6902Patches []ManifestPatch `json:"json6902Patches,omitempty"`
+	// ImageRewrites replace the prefix of container images, i.e. to pull images from a mirror
+	// +optional
+	ImageRewrites []ImageRewrite `json:"imageRewrites,omitempty"`
+}
+
+// ManifestPatch is a patch applied to every rendered resource matching the target
+type ManifestPatch struct {
+	// Target selects the resources to patch. All resources are patched if it is empty
+	// +optional
+	Target PatchTarget `json:"target,omitempty"`
+	// Patch is the yaml or json patch
+	Patch string `json:"patch"`
+}
+
+// PatchTarget selects rendered resources. Empty fields match everything
+type PatchTarget struct {
+	// +optional
+	Group string `json:"group,omitempty"`
+	// +optional
+	Version string `json:"version,omitempty"`
+	// +optional
+	Kind string `json:"kind,omitempty"`
+	// Namespace of the resource. Resources without a namespace are in the release namespace
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// +optional
+	Name string `json:"name,omitempty"`
+	// LabelSelector is a label selector in the same format as kubectl --selector
+	// +optional
+	LabelSelector string `json:"labelSelector,omitempty"`
+}
+
+// ImageRewrite replaces the prefix of container images
+type ImageRewrite struct {
+	// From is the image prefix to replace, i.e. docker.io/bitnami
+	From string `json:"from"`
+	// To is what the prefix is replaced with, i.e. mirror.example.com/bitnami
+	To string `json:"to"`
 }
 
 // ControllerWatchStatus defines the observed state of ControllerWatch.
@@ -84,6 +374,155 @@ type ControllerWatchStatus struct {
 	// LastUpdated is the last time which this status was updated
 	// +optional
 	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+	// LastTriggered is the last time which usage of a custom resource triggered the installation of the controller
+	// +optional
+	LastTriggered *metav1.Time `json:"lastTriggered,omitempty"`
+	// InstallProgress is the progress of the current or last installation of the controller
+	// +optional
+	InstallProgress *InstallProgress `json:"installProgress,omitempty"`
+	// ObservedGeneration is the generation of the spec which the CRDs were last installed from
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Conditions describe the current state of this ControllerWatch
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// StorageVersionMigrations is the progress of migrating the stored objects of the installed CRDs to their storage version
+	// +optional
+	// +listType=map
+	// +listMapKey=crd
+	StorageVersionMigrations []StorageVersionMigrationStatus `json:"storageVersionMigrations,omitempty"`
+	// Plan is what installing the chart would do. It is only computed while spec.dryRun is set
+	// +optional
+	Plan *Plan `json:"plan,omitempty"`
+	// Usage is the inventory of the custom resources of every watched kind
+	// +optional
+	// +listType=map
+	// +listMapKey=group
+	// +listMapKey=kind
+	Usage []KindUsage `json:"usage,omitempty"`
+	// LastTrigger is the custom resource which triggered the most recent installation of the controller
+	// +optional
+	LastTrigger *TriggerSource `json:"lastTrigger,omitempty"`
+	// ResourceRequests are the total resource requests of the workloads of the controller, which are counted against the
+	// hoist budget while it is installed
+	// +optional
+	ResourceRequests corev1.ResourceList `json:"resourceRequests,omitempty"`
+}
+
+// KindUsage is the inventory of the custom resources of a single kind. It covers every served version of the kind
+type KindUsage struct {
+	Group string `json:"group"`
+	Kind  string `json:"kind"`
+	// Count is how many custom resources of the kind currently exist
+	Count int32 `json:"count"`
+	// FirstSeen is when a custom resource of the kind was first seen
+	// +optional
+	FirstSeen *metav1.Time `json:"firstSeen,omitempty"`
+	// LastSeen is the last time a custom resource of the kind was seen to exist. It is updated at most once a minute
+	// unless the count or namespaces change
+	// +optional
+	LastSeen *metav1.Time `json:"lastSeen,omitempty"`
+	// Namespaces which have custom resources of the kind
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// TriggerSource is a custom resource which triggered the installation of the controller
+type TriggerSource struct {
+	GroupVersionKind `json:",inline"`
+	// Namespace of the custom resource, empty if it is cluster scoped
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Creator is the field manager which created the custom resource
+	// +optional
+	Creator string `json:"creator,omitempty"`
+	// Time is when the installation was triggered
+	Time metav1.Time `json:"time"`
+}
+
+// InstallProgress is the progress of an installation of the controller, which runs in the background
+type InstallProgress struct {
+	// Generation of the spec which is being installed
+	Generation int64 `json:"generation"`
+	// State is Queued, Running, Succeeded or Failed
+	State string `json:"state"`
+	// QueuedAt is when the installation was queued
+	QueuedAt metav1.Time `json:"queuedAt"`
+	// StartedAt is when the installation started running
+	// +optional
+	StartedAt *metav1.Time `json:"startedAt,omitempty"`
+	// FinishedAt is when the installation finished
+	// +optional
+	FinishedAt *metav1.Time `json:"finishedAt,omitempty"`
+	// Message is the error of a failed installation
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Logs is the tail of the logs of the installer Job, when installs run in Jobs
+	// +optional
+	Logs string `json:"logs,omitempty"`
+}
+
+// Plan is what installing the chart of a ControllerWatch would do
+type Plan struct {
+	// ObservedGeneration is the generation of the spec which the plan was computed for
+	ObservedGeneration int64 `json:"observedGeneration"`
+	// ComputedAt is when the plan was computed
+	ComputedAt metav1.Time `json:"computedAt"`
+	// CRDs are the CRDs which would be applied
+	// +optional
+	CRDs []PlannedCRD `json:"crds,omitempty"`
+	// Resources are the resources which installing the controller would create, excluding CRDs
+	// +optional
+	Resources []PlannedResource `json:"resources,omitempty"`
+	// Error is why the plan could not be computed, if it couldn't
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// PlannedCRD is what applying a CRD would do
+type PlannedCRD struct {
+	// Name of the CRD
+	Name string `json:"name"`
+	// Action is Create, Update or Unchanged
+	Action string `json:"action"`
+	// Findings of the CRD upgrade checks against the installed CRD
+	// +optional
+	Findings []string `json:"findings,omitempty"`
+}
+
+// PlannedResource is a resource which installing the controller would create or change
+type PlannedResource struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Action is Create, Update or Unchanged, or empty if the server rejected the dry-run apply
+	// +optional
+	Action string `json:"action,omitempty"`
+	// Message is why the server rejected the dry-run apply, i.e. because the kind is a custom resource of a CRD
+	// which is not installed yet
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// StorageVersionMigrationStatus is the progress of migrating the stored objects of a CRD to its storage version
+type StorageVersionMigrationStatus struct {
+	// CRD is the name of the CRD being migrated
+	CRD string `json:"crd"`
+	// StorageVersion is the version which the objects are migrated to
+	StorageVersion string `json:"storageVersion"`
+	// State of the migration
+	State StorageVersionMigrationState `json:"state"`
+	// Migrated is the number of objects which have been rewritten so far
+	// +optional
+	Migrated int32 `json:"migrated,omitempty"`
+	// Message describes why the migration failed, if it did
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 type GroupVersionKind struct {
@@ -117,6 +556,25 @@ type ControllerWatch struct {
 	Status ControllerWatchStatus `json:"status,omitempty"`
 }
 
+// GetSpec returns the spec of this ControllerWatch
+func (c *ControllerWatch) GetSpec() *ControllerWatchSpec {
+	return &c.Spec
+}
+
+// GetStatus returns the status of this ControllerWatch
+func (c *ControllerWatch) GetStatus() *ControllerWatchStatus {
+	return &c.Status
+}
+
+// ControllerWatchObject is implemented by both ControllerWatch and NamespacedControllerWatch
+// so that they can share the same reconciliation logic
+// +kubebuilder:object:generate=false
+type ControllerWatchObject interface {
+	client.Object
+	GetSpec() *ControllerWatchSpec
+	GetStatus() *ControllerWatchStatus
+}
+
 // +kubebuilder:object:root=true
 
 // ControllerWatchList contains a list of ControllerWatch.
@@ -0,0 +1,118 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// MaxDeadLetters is how many failed deliveries are kept in the status of a HoistNotifier
+	MaxDeadLetters = 10
+)
+
+// HoistNotifierSpec defines where and how lifecycle transitions of controllers are sent
+type HoistNotifierSpec struct {
+	// URL of the HTTP webhook which the notifications are POSTed to
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+	// SigningKeySecretRef if set signs the body of every notification with HMAC-SHA256, using the key in this secret in
+	// the namespace of kubehoist. The signature is sent in the X-Kubehoist-Signature header as sha256=<hex>
+	// +optional
+	SigningKeySecretRef *SecretKeyReference `json:"signingKeySecretRef,omitempty"`
+	// Events are the installation statuses of controllers which are sent when a controller transitions into them.
+	// All transitions are sent if empty
+	// +optional
+	Events []ControllerInstallationStatus `json:"events,omitempty"`
+	// PayloadTemplate is a Go template which renders the JSON body of a notification. The fields of the notification are
+	// available in the template, and the json function quotes a value as JSON. The notification itself is sent as JSON
+	// if empty
+	// +optional
+	PayloadTemplate string `json:"payloadTemplate,omitempty"`
+	// MaxAttempts is how many times a notification is POSTed before it is given up and recorded as a dead letter
+	// +kubebuilder:default=5
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxAttempts int32 `json:"maxAttempts,omitempty"`
+}
+
+// SecretKeyReference selects a key of a secret in the namespace of kubehoist
+type SecretKeyReference struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+// DeadLetter is a notification which could not be delivered
+type DeadLetter struct {
+	// Event is the installation status which the controller transitioned into
+	Event ControllerInstallationStatus `json:"event"`
+	// ControllerWatch is the kind/namespace/name of the ControllerWatch which the notification is about
+	ControllerWatch string `json:"controllerWatch"`
+	// Time is when the notification was given up
+	Time metav1.Time `json:"time"`
+	// Attempts is how many times the notification was POSTed
+	Attempts int32 `json:"attempts"`
+	// Error of the last attempt
+	Error string `json:"error"`
+	// Payload is the body of the notification
+	// +optional
+	Payload string `json:"payload,omitempty"`
+}
+
+// HoistNotifierStatus defines the observed state of HoistNotifier
+type HoistNotifierStatus struct {
+	// Delivered is how many notifications have been delivered
+	// +optional
+	Delivered int64 `json:"delivered,omitempty"`
+	// Failed is how many notifications were given up
+	// +optional
+	Failed int64 `json:"failed,omitempty"`
+	// LastDelivered is when a notification was last delivered
+	// +optional
+	LastDelivered *metav1.Time `json:"lastDelivered,omitempty"`
+	// DeadLetters are the most recent notifications which could not be delivered, oldest first
+	// +optional
+	DeadLetters []DeadLetter `json:"deadLetters,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+
+// HoistNotifier is the Schema for the hoistnotifiers API.
+// It POSTs a notification to an HTTP webhook whenever the controller of a ControllerWatch or NamespacedControllerWatch
+// transitions to another installation status
+type HoistNotifier struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HoistNotifierSpec   `json:"spec,omitempty"`
+	Status HoistNotifierStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// HoistNotifierList contains a list of HoistNotifier.
+type HoistNotifierList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HoistNotifier `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HoistNotifier{}, &HoistNotifierList{})
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Namespaced,shortName=ncw
+
+// NamespacedControllerWatch is the Schema for the namespacedcontrollerwatches API.
+// It behaves like a ControllerWatch, but the chart may only be installed into the namespace of this resource,
+// only custom resources created in that namespace will trigger the installation, and the chart CRDs must be
+// in the allowlist approved by the cluster admin.
+type NamespacedControllerWatch struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ControllerWatchSpec   `json:"spec,omitempty"`
+	Status ControllerWatchStatus `json:"status,omitempty"`
+}
+
+// GetSpec returns the spec of this NamespacedControllerWatch
+func (c *NamespacedControllerWatch) GetSpec() *ControllerWatchSpec {
+	return &c.Spec
+}
+
+// GetStatus returns the status of this NamespacedControllerWatch
+func (c *NamespacedControllerWatch) GetStatus() *ControllerWatchStatus {
+	return &c.Status
+}
+
+// +kubebuilder:object:root=true
+
+// NamespacedControllerWatchList contains a list of NamespacedControllerWatch.
+type NamespacedControllerWatchList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NamespacedControllerWatch `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NamespacedControllerWatch{}, &NamespacedControllerWatchList{})
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/cheeseandcereal/kubehoist/pkg/controller"
+	"github.com/cheeseandcereal/kubehoist/pkg/helm"
+	"github.com/cheeseandcereal/kubehoist/pkg/installer"
+)
+
+// terminationMessagePath is where the result of the installer is written, so that the manager can read it from the
+// status of the pod
+const terminationMessagePath = "/dev/termination-log"
+
+// runInstaller implements the installer subcommand, which installs a chart inside a Job created by the manager.
+// It returns the exit code
+func runInstaller() int {
+	err := install()
+	message := "installed"
+	if err != nil {
+		message = err.Error()
+		fmt.Fprintf(os.Stderr, "install failed: %v\n", err)
+	}
+	if writeErr := os.WriteFile(terminationMessagePath, []byte(message), 0o644); writeErr != nil {
+		fmt.Fprintf(os.Stderr, "failed to write termination message: %v\n", writeErr)
+	}
+	if err != nil {
+		return 1
+	}
+	fmt.Println(message)
+	return 0
+}
+
+func install() error {
+	request, err := installer.ReadRequest(installer.RequestPath)
+	if err != nil {
+		return err
+	}
+	opts, err := controller.HelmInstallOptions(&request.Spec)
+	if err != nil {
+		return err
+	}
+	if request.Namespace != "" {
+		if err := controller.RestrictNamespacedInstallOptions(request.Namespace, request.AllowedCRDGroups, &opts); err != nil {
+			return err
+		}
+	}
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		return err
+	}
+	helmClient, err := helm.NewHelmClient(config, nil, func(format string, v ...interface{}) {
+		fmt.Printf(format+"\n", v...)
+	})
+	if err != nil {
+		return err
+	}
+	return helmClient.InstallChart(ctrl.SetupSignalHandler(), opts)
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+)
+
+var _ = Describe("Commands", func() {
+	var kclient client.Client
+	var o *options
+	var out *bytes.Buffer
+	ctx := context.Background()
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	newEvent := func(name string, uid types.UID, at time.Time, reason string) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Namespace: "default", Name: name},
+			InvolvedObject: corev1.ObjectReference{UID: uid},
+			LastTimestamp:  metav1.NewTime(at),
+			Type:           corev1.EventTypeNormal,
+			Reason:         reason,
+			Message:        reason + " message",
+		}
+	}
+
+	run := func(cmd *cobra.Command, args ...string) error {
+		cmd.SetArgs(args)
+		cmd.SetOut(out)
+		cmd.SetErr(out)
+		return cmd.ExecuteContext(ctx)
+	}
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(controllerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		Expect(corev1.AddToScheme(scheme)).To(Succeed())
+		objects := []client.Object{
+			&controllerv1alpha1.ControllerWatch{ObjectMeta: metav1.ObjectMeta{
+				Name: "example", UID: "cw-uid", Annotations: map[string]string{"keep": "me"},
+			}},
+			&controllerv1alpha1.NamespacedControllerWatch{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "example", UID: "ncw-uid"}},
+			&controllerv1alpha1.NamespacedControllerWatch{ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "example", UID: "other-uid"}},
+			newEvent("installed", "cw-uid", start.Add(2*time.Minute), "Installed"),
+			newEvent("requested", "cw-uid", start.Add(time.Minute), "Requested"),
+			newEvent("other", "other-uid", start, "Other"),
+		}
+		kclient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).
+			WithIndex(&corev1.Event{}, "involvedObject.uid", func(obj client.Object) []string {
+				return []string{string(obj.(*corev1.Event).InvolvedObject.UID)}
+			}).Build()
+		configFlags := genericclioptions.NewConfigFlags(false)
+		*configFlags.Namespace = "team-a"
+		out = &bytes.Buffer{}
+		o = &options{configFlags: configFlags, IOStreams: genericiooptions.IOStreams{Out: out, ErrOut: out}, kclient: kclient}
+	})
+
+	It("requests a wake by annotating a ControllerWatch", func() {
+		Expect(run(newWakeCommand(o), "example")).To(Succeed())
+		Expect(out.String()).To(Equal("example: wake requested\n"))
+		controllerWatch := &controllerv1alpha1.ControllerWatch{}
+		Expect(kclient.Get(ctx, client.ObjectKey{Name: "example"}, controllerWatch)).To(Succeed())
+		Expect(controllerWatch.Annotations).To(Equal(map[string]string{
+			"keep":                             "me",
+			controllerv1alpha1.HoistAnnotation: controllerv1alpha1.ManualRequestValue,
+		}))
+	})
+
+	It("requests a sleep by annotating a NamespacedControllerWatch in the current namespace", func() {
+		Expect(run(newSleepCommand(o), "ncw/example")).To(Succeed())
+		Expect(out.String()).To(Equal("ncw/example: sleep requested\n"))
+		controllerWatch := &controllerv1alpha1.NamespacedControllerWatch{}
+		Expect(kclient.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: "example"}, controllerWatch)).To(Succeed())
+		Expect(controllerWatch.Annotations).To(HaveKeyWithValue(controllerv1alpha1.SleepAnnotation, controllerv1alpha1.ManualRequestValue))
+		Expect(kclient.Get(ctx, client.ObjectKey{Namespace: "team-b", Name: "example"}, controllerWatch)).To(Succeed())
+		Expect(controllerWatch.Annotations).To(BeEmpty())
+	})
+
+	It("rejects an unknown kind", func() {
+		Expect(run(newWakeCommand(o), "deploy/example")).To(MatchError(ContainSubstring("unknown kind")))
+	})
+
+	It("explains a watch with only its own events, oldest first", func() {
+		controllerWatch := &controllerv1alpha1.ControllerWatch{}
+		Expect(kclient.Get(ctx, client.ObjectKey{Name: "example"}, controllerWatch)).To(Succeed())
+		cmd := &cobra.Command{}
+		cmd.SetContext(ctx)
+		timeline, err := buildTimeline(cmd, kclient, controllerWatch)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(timeline).To(HaveLen(2))
+		Expect(timeline[0].reason).To(Equal("Requested"))
+		Expect(timeline[1].reason).To(Equal("Installed"))
+		Expect(timeline[1].source).To(Equal("Event/Normal"))
+
+		Expect(run(newWhyCommand(o), "example")).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("Installed message"))
+		Expect(out.String()).NotTo(ContainSubstring("Other"))
+	})
+})
@@ -0,0 +1,75 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+func newListCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List watches with their state, installed kinds and last trigger",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kclient, err := o.client()
+			if err != nil {
+				return err
+			}
+			controllerWatches, err := o.listControllerWatches(cmd, kclient)
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(o.Out, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "NAMESPACE\tNAME\tCRDS\tCONTROLLER\tKINDS\tLAST TRIGGERED")
+			for _, controllerWatch := range controllerWatches {
+				status := controllerWatch.GetStatus()
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n",
+					valueOrNone(controllerWatch.GetNamespace()),
+					displayName(controllerWatch),
+					valueOrNone(string(status.CRDsInstallationStatus)),
+					valueOrNone(string(status.ControllerInstallationStatus)),
+					len(status.InstalledCRDs),
+					age(status.LastTriggered),
+				)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().BoolVarP(&o.allNamespaces, "all-namespaces", "A", false, "List NamespacedControllerWatches in all namespaces")
+	return cmd
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
+
+func age(t *metav1.Time) string {
+	if t == nil {
+		return "<never>"
+	}
+	return duration.HumanDuration(time.Since(t.Time)) + " ago"
+}
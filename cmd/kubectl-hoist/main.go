@@ -0,0 +1,147 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-hoist is a kubectl plugin for inspecting and operating ControllerWatches
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/cli-runtime/pkg/genericiooptions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+)
+
+// options are shared by all of the subcommands
+type options struct {
+	configFlags   *genericclioptions.ConfigFlags
+	allNamespaces bool
+	genericiooptions.IOStreams
+
+	// kclient replaces the client built from configFlags when set
+	kclient client.Client
+}
+
+func main() {
+	o := &options{
+		configFlags: genericclioptions.NewConfigFlags(true),
+		IOStreams:   genericiooptions.IOStreams{In: os.Stdin, Out: os.Stdout, ErrOut: os.Stderr},
+	}
+	cmd := &cobra.Command{
+		Use:          "kubectl-hoist",
+		Short:        "Inspect and operate kubehoist ControllerWatches",
+		SilenceUsage: true,
+	}
+	o.configFlags.AddFlags(cmd.PersistentFlags())
+	cmd.AddCommand(newListCommand(o), newWakeCommand(o), newSleepCommand(o), newWhyCommand(o), newUsageCommand(o))
+	if err := cmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func (o *options) client() (client.Client, error) {
+	if o.kclient != nil {
+		return o.kclient, nil
+	}
+	config, err := o.configFlags.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	scheme := runtime.NewScheme()
+	if err := controllerv1alpha1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	return client.New(config, client.Options{Scheme: scheme})
+}
+
+func (o *options) namespace() (string, error) {
+	namespace, _, err := o.configFlags.ToRawKubeConfigLoader().Namespace()
+	return namespace, err
+}
+
+// getControllerWatch fetches the watch named by a NAME argument, which refers to a ControllerWatch,
+// or a ncw/NAME argument, which refers to a NamespacedControllerWatch in the current namespace
+func (o *options) getControllerWatch(cmd *cobra.Command, kclient client.Client, arg string) (controllerv1alpha1.ControllerWatchObject, error) {
+	var controllerWatch controllerv1alpha1.ControllerWatchObject = &controllerv1alpha1.ControllerWatch{}
+	key := client.ObjectKey{Name: arg}
+	if kind, name, ok := strings.Cut(arg, "/"); ok {
+		switch strings.ToLower(kind) {
+		case "ncw", "namespacedcontrollerwatch", "namespacedcontrollerwatches":
+			namespace, err := o.namespace()
+			if err != nil {
+				return nil, err
+			}
+			controllerWatch = &controllerv1alpha1.NamespacedControllerWatch{}
+			key = client.ObjectKey{Namespace: namespace, Name: name}
+		case "cw", "controllerwatch", "controllerwatches":
+			key = client.ObjectKey{Name: name}
+		default:
+			return nil, fmt.Errorf("unknown kind %q, expected cw or ncw", kind)
+		}
+	}
+	if err := kclient.Get(cmd.Context(), key, controllerWatch); err != nil {
+		return nil, err
+	}
+	return controllerWatch, nil
+}
+
+// listControllerWatches lists all ControllerWatches, followed by the NamespacedControllerWatches in the current
+// namespace, or in all namespaces with --all-namespaces
+func (o *options) listControllerWatches(cmd *cobra.Command, kclient client.Client) ([]controllerv1alpha1.ControllerWatchObject, error) {
+	controllerWatches := []controllerv1alpha1.ControllerWatchObject{}
+	clusterList := &controllerv1alpha1.ControllerWatchList{}
+	if err := kclient.List(cmd.Context(), clusterList); err != nil {
+		return nil, err
+	}
+	for i := range clusterList.Items {
+		controllerWatches = append(controllerWatches, &clusterList.Items[i])
+	}
+
+	listOpts := []client.ListOption{}
+	if !o.allNamespaces {
+		namespace, err := o.namespace()
+		if err != nil {
+			return nil, err
+		}
+		listOpts = append(listOpts, client.InNamespace(namespace))
+	}
+	namespacedList := &controllerv1alpha1.NamespacedControllerWatchList{}
+	if err := kclient.List(cmd.Context(), namespacedList, listOpts...); err != nil {
+		return nil, err
+	}
+	for i := range namespacedList.Items {
+		controllerWatches = append(controllerWatches, &namespacedList.Items[i])
+	}
+	return controllerWatches, nil
+}
+
+// displayName is the name of a watch in the form accepted as an argument
+func displayName(controllerWatch controllerv1alpha1.ControllerWatchObject) string {
+	if controllerWatch.GetNamespace() != "" {
+		return "ncw/" + controllerWatch.GetName()
+	}
+	return controllerWatch.GetName()
+}
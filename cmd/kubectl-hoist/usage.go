@@ -0,0 +1,72 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newUsageCommand(o *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Count the custom resources of every kind installed by the watches",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kclient, err := o.client()
+			if err != nil {
+				return err
+			}
+			controllerWatches, err := o.listControllerWatches(cmd, kclient)
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(o.Out, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "NAMESPACE\tNAME\tKIND\tCOUNT")
+			for _, controllerWatch := range controllerWatches {
+				counted := map[schema.GroupKind]bool{}
+				for _, crd := range controllerWatch.GetStatus().InstalledCRDs {
+					gvk := crd.ToSchemaGVK()
+					// All versions of a kind are the same objects, so only count each kind once
+					if counted[gvk.GroupKind()] {
+						continue
+					}
+					counted[gvk.GroupKind()] = true
+
+					count := "<unknown>"
+					list := &metav1.PartialObjectMetadataList{}
+					list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+					// Custom resources of a NamespacedControllerWatch only count in its own namespace
+					if err := kclient.List(cmd.Context(), list, client.InNamespace(controllerWatch.GetNamespace())); err == nil {
+						count = fmt.Sprint(len(list.Items))
+					} else {
+						fmt.Fprintf(o.ErrOut, "failed to list %s: %v\n", gvk, err)
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", valueOrNone(controllerWatch.GetNamespace()), displayName(controllerWatch), gvk.GroupKind(), count)
+				}
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().BoolVarP(&o.allNamespaces, "all-namespaces", "A", false, "Include NamespacedControllerWatches in all namespaces")
+	return cmd
+}
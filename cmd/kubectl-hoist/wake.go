@@ -0,0 +1,74 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+)
+
+func newWakeCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "wake (NAME | ncw/NAME)",
+		Short: "Install the controller of a watch now",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.requestManually(cmd, args[0], controllerv1alpha1.HoistAnnotation, "wake")
+		},
+	}
+}
+
+func newSleepCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "sleep (NAME | ncw/NAME)",
+		Short: "Uninstall the controller of a watch now",
+		Long: "Uninstall the controller of a watch now. " +
+			"The controller is installed again the next time one of its custom resources is used",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.requestManually(cmd, args[0], controllerv1alpha1.SleepAnnotation, "sleep")
+		},
+	}
+}
+
+// requestManually sets one of the manual request annotations, which kubehoist removes once it has acted on it
+func (o *options) requestManually(cmd *cobra.Command, arg, annotation, verb string) error {
+	kclient, err := o.client()
+	if err != nil {
+		return err
+	}
+	controllerWatch, err := o.getControllerWatch(cmd, kclient, arg)
+	if err != nil {
+		return err
+	}
+	patch := client.MergeFrom(controllerWatch.DeepCopyObject().(client.Object))
+	annotations := controllerWatch.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[annotation] = controllerv1alpha1.ManualRequestValue
+	controllerWatch.SetAnnotations(annotations)
+	if err := kclient.Patch(cmd.Context(), controllerWatch, patch); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Out, "%s: %s requested\n", displayName(controllerWatch), verb)
+	return nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+)
+
+// timelineEntry is a single line of the why timeline
+type timelineEntry struct {
+	time    time.Time
+	source  string
+	reason  string
+	message string
+}
+
+func newWhyCommand(o *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "why (NAME | ncw/NAME)",
+		Short: "Explain the current state of a watch with a timeline of its events and conditions",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kclient, err := o.client()
+			if err != nil {
+				return err
+			}
+			controllerWatch, err := o.getControllerWatch(cmd, kclient, args[0])
+			if err != nil {
+				return err
+			}
+			status := controllerWatch.GetStatus()
+			fmt.Fprintf(o.Out, "Name:        %s\n", displayName(controllerWatch))
+			fmt.Fprintf(o.Out, "CRDs:        %s\n", valueOrNone(string(status.CRDsInstallationStatus)))
+			fmt.Fprintf(o.Out, "Controller:  %s\n", valueOrNone(string(status.ControllerInstallationStatus)))
+			fmt.Fprintf(o.Out, "Triggered:   %s\n\n", age(status.LastTriggered))
+
+			timeline, err := buildTimeline(cmd, kclient, controllerWatch)
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(o.Out, 0, 8, 2, ' ', 0)
+			fmt.Fprintln(w, "TIME\tSOURCE\tREASON\tMESSAGE")
+			for _, entry := range timeline {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", entry.time.Local().Format(time.DateTime), entry.source, entry.reason, entry.message)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+// buildTimeline merges the events of a watch with the transitions of its conditions, oldest first
+func buildTimeline(cmd *cobra.Command, kclient client.Client, controllerWatch controllerv1alpha1.ControllerWatchObject) ([]timelineEntry, error) {
+	timeline := []timelineEntry{}
+	status := controllerWatch.GetStatus()
+	if status.LastTriggered != nil {
+		message := "Custom resource usage triggered the installation"
+		if trigger := status.LastTrigger; trigger != nil {
+			name := trigger.Name
+			if trigger.Namespace != "" {
+				name = trigger.Namespace + "/" + name
+			}
+			message = fmt.Sprintf("%s %s triggered the installation", trigger.Kind, name)
+			if trigger.Creator != "" {
+				message += fmt.Sprintf(" (created by %s)", trigger.Creator)
+			}
+		}
+		timeline = append(timeline, timelineEntry{time: status.LastTriggered.Time, source: "Status", reason: "Triggered", message: message})
+	}
+	for _, condition := range status.Conditions {
+		timeline = append(timeline, timelineEntry{
+			time:    condition.LastTransitionTime.Time,
+			source:  "Condition",
+			reason:  fmt.Sprintf("%s=%s (%s)", condition.Type, condition.Status, condition.Reason),
+			message: condition.Message,
+		})
+	}
+
+	// Events of cluster scoped objects are recorded in the default namespace, so search all namespaces
+	events := &corev1.EventList{}
+	if err := kclient.List(cmd.Context(), events, client.MatchingFields{"involvedObject.uid": string(controllerWatch.GetUID())}); err != nil {
+		return nil, err
+	}
+	for _, event := range events.Items {
+		eventTime := event.LastTimestamp.Time
+		if eventTime.IsZero() {
+			eventTime = event.EventTime.Time
+		}
+		if eventTime.IsZero() {
+			eventTime = event.CreationTimestamp.Time
+		}
+		message := event.Message
+		if event.Count > 1 {
+			message = fmt.Sprintf("%s (x%d)", message, event.Count)
+		}
+		timeline = append(timeline, timelineEntry{time: eventTime, source: "Event/" + event.Type, reason: event.Reason, message: message})
+	}
+
+	slices.SortStableFunc(timeline, func(a, b timelineEntry) int { return a.time.Compare(b.time) })
+	return timeline, nil
+}
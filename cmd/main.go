@@ -21,6 +21,10 @@ import (
 	"flag"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
+	// Embed the time zone database so that schedule time zones work in minimal images
+	_ "time/tzdata"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -29,6 +33,7 @@ import (
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
@@ -39,8 +44,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/budget"
 	"github.com/cheeseandcereal/kubehoist/pkg/controller"
 	"github.com/cheeseandcereal/kubehoist/pkg/helm"
+	"github.com/cheeseandcereal/kubehoist/pkg/installer"
+	"github.com/cheeseandcereal/kubehoist/pkg/installpool"
+	"github.com/cheeseandcereal/kubehoist/pkg/notify"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -60,13 +69,26 @@ func init() {
 
 // nolint:gocyclo
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		os.Exit(runRender(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == installer.Subcommand {
+		os.Exit(runInstaller())
+	}
+
 	var metricsAddr string
 	var metricsCertPath, metricsCertName, metricsCertKey string
 	var webhookCertPath, webhookCertName, webhookCertKey string
 	var enableLeaderElection bool
+	var leaseDuration, renewDeadline, retryPeriod time.Duration
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var namespacedCRDGroups string
+	var maxConcurrentInstalls int
+	var installMode, installerImage, installerNamespace, installerServiceAccount string
+	var budgetCount int
+	var budgetCPU, budgetMemory string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -74,6 +96,12 @@ func main() {
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&leaseDuration, "leader-elect-lease-duration", 15*time.Second,
+		"How long other replicas wait before taking over the leadership of a leader which stopped renewing it.")
+	flag.DurationVar(&renewDeadline, "leader-elect-renew-deadline", 10*time.Second,
+		"How long the leader retries renewing its leadership before giving it up.")
+	flag.DurationVar(&retryPeriod, "leader-elect-retry-period", 2*time.Second,
+		"How often replicas try to acquire or renew the leadership.")
 	flag.BoolVar(&secureMetrics, "metrics-secure", true,
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.StringVar(&webhookCertPath, "webhook-cert-path", "", "The directory that contains the webhook certificate.")
@@ -85,6 +113,25 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&namespacedCRDGroups, "namespaced-crd-allowed-groups", "",
+		"Comma separated list of CRD API groups which NamespacedControllerWatch resources are allowed to install. "+
+			"If empty, NamespacedControllerWatch resources can not install any CRDs.")
+	flag.IntVar(&maxConcurrentInstalls, "max-concurrent-installs", 2,
+		"The maximum number of helm installs which run at the same time.")
+	flag.StringVar(&installMode, "install-mode", "inprocess",
+		"Where helm installs run. Either inprocess, or job to run each install in a Kubernetes Job.")
+	flag.StringVar(&installerImage, "installer-image", "",
+		"The image of the installer Jobs, which should be the image of the manager. Required with --install-mode=job.")
+	flag.StringVar(&installerNamespace, "installer-namespace", os.Getenv("POD_NAMESPACE"),
+		"The namespace which installer Jobs are created in. Defaults to the namespace of the manager.")
+	flag.StringVar(&installerServiceAccount, "installer-service-account", "kubehoist-controller-manager",
+		"The service account which installer Jobs run as.")
+	flag.IntVar(&budgetCount, "hoist-budget-count", 0,
+		"The maximum number of controllers which are hoisted at the same time. 0 means unlimited.")
+	flag.StringVar(&budgetCPU, "hoist-budget-cpu", "",
+		"The maximum total CPU requests of the hoisted controllers, for example 4 or 1500m. Empty means unlimited.")
+	flag.StringVar(&budgetMemory, "hoist-budget-memory", "",
+		"The maximum total memory requests of the hoisted controllers, for example 8Gi. Empty means unlimited.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -182,12 +229,6 @@ func main() {
 		})
 	}
 
-	helmClient, err := helm.NewHelmClient(ctrl.Log.WithName("helm").V(3).Info)
-	if err != nil {
-		setupLog.Error(err, "unable to create helm client")
-		os.Exit(1)
-	}
-
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		Metrics:                metricsServerOptions,
@@ -195,31 +236,97 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "7a639403.kubehoist.io",
-		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
-		// when the Manager ends. This requires the binary to immediately end when the
-		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
-		// speeds up voluntary leader transitions as the new leader don't have to wait
-		// LeaseDuration time first.
-		//
-		// In the default scaffold provided, the program ends immediately after
-		// the manager stops, so would be fine to enable this option. However,
-		// if you are doing or is intended to do any operation such as perform cleanups
-		// after the manager stops then its usage might be unsafe.
-		// LeaderElectionReleaseOnCancel: true,
+		LeaseDuration:          &leaseDuration,
+		RenewDeadline:          &renewDeadline,
+		RetryPeriod:            &retryPeriod,
+		// The program ends immediately after the manager stops, so the leader can step down right away on shutdown
+		// instead of replicas waiting for the lease to expire
+		LeaderElectionReleaseOnCancel: true,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	helmClient, err := helm.NewHelmClient(mgr.GetConfig(), mgr.GetRESTMapper(), ctrl.Log.WithName("helm").V(3).Info)
+	if err != nil {
+		setupLog.Error(err, "unable to create helm client")
+		os.Exit(1)
+	}
+
+	var installerJobs *installer.JobRunner
+	switch installMode {
+	case "inprocess":
+	case "job":
+		if installerImage == "" || installerNamespace == "" {
+			setupLog.Error(nil, "--installer-image and --installer-namespace are required with --install-mode=job")
+			os.Exit(1)
+		}
+		clientset, err := kubernetes.NewForConfig(mgr.GetConfig())
+		if err != nil {
+			setupLog.Error(err, "unable to create kubernetes clientset")
+			os.Exit(1)
+		}
+		installerJobs = &installer.JobRunner{
+			Client:             mgr.GetClient(),
+			Reader:             mgr.GetAPIReader(),
+			Clientset:          clientset,
+			Image:              installerImage,
+			Namespace:          installerNamespace,
+			ServiceAccountName: installerServiceAccount,
+		}
+	default:
+		setupLog.Error(nil, "invalid --install-mode, must be inprocess or job", "install-mode", installMode)
+		os.Exit(1)
+	}
+
+	hoistBudget, err := budget.ParseLimits(budgetCount, budgetCPU, budgetMemory)
+	if err != nil {
+		setupLog.Error(err, "invalid hoist budget")
+		os.Exit(1)
+	}
+
+	budgetAdmissions := budget.NewAdmissions(controller.AdmissionTTL)
+
+	installPool := installpool.New(maxConcurrentInstalls)
+	if err := mgr.Add(installPool); err != nil {
+		setupLog.Error(err, "unable to add install pool")
+		os.Exit(1)
+	}
+
+	if err := mgr.Add(notify.New(mgr.GetClient(), mgr.GetAPIReader(), mgr.GetCache(), os.Getenv("POD_NAMESPACE"))); err != nil {
+		setupLog.Error(err, "unable to add notifier")
+		os.Exit(1)
+	}
+
 	if err = (&controller.ControllerWatchReconciler{
-		Client:     mgr.GetClient(),
-		Manager:    mgr,
-		HelmClient: helmClient,
+		Client:           mgr.GetClient(),
+		Manager:          mgr,
+		HelmClient:       helmClient,
+		Recorder:         mgr.GetEventRecorderFor("controllerwatch-controller"),
+		InstallPool:      installPool,
+		InstallerJobs:    installerJobs,
+		Budget:           &hoistBudget,
+		BudgetAdmissions: budgetAdmissions,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ControllerWatch")
 		os.Exit(1)
 	}
+	if err = (&controller.ControllerWatchReconciler{
+		Client:                     mgr.GetClient(),
+		Manager:                    mgr,
+		HelmClient:                 helmClient,
+		Recorder:                   mgr.GetEventRecorderFor("namespacedcontrollerwatch-controller"),
+		InstallPool:                installPool,
+		InstallerJobs:              installerJobs,
+		Budget:                     &hoistBudget,
+		BudgetAdmissions:           budgetAdmissions,
+		Namespaced:                 true,
+		AllowedNamespacedCRDGroups: splitList(namespacedCRDGroups),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "NamespacedControllerWatch")
+		os.Exit(1)
+	}
 	// +kubebuilder:scaffold:builder
 
 	if metricsCertWatcher != nil {
@@ -253,3 +360,14 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// splitList splits a comma separated flag value, dropping surrounding whitespace and empty entries
+func splitList(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/controller"
+	"github.com/cheeseandcereal/kubehoist/pkg/helm"
+)
+
+// runRender implements the render subcommand, which previews the CRDs kubehoist would extract from a chart and the
+// kinds it would watch, without a cluster. It returns the exit code, which is non-zero if no CRDs would be found
+func runRender(args []string, out, errOut io.Writer) int {
+	var file, chart, version, namespace, releaseName, valuesFile, crdValuesFile string
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	fs.StringVar(&file, "f", "", "A ControllerWatch or NamespacedControllerWatch manifest to read the chart and values from")
+	fs.StringVar(&chart, "chart", "", "The chart to render. Overrides the chart from -f")
+	fs.StringVar(&version, "version", "", "The version of the chart. Overrides the version from -f")
+	fs.StringVar(&namespace, "namespace", "", "The namespace to render the chart for. Overrides the namespace from -f")
+	fs.StringVar(&releaseName, "release-name", "", "The release name to render the chart with. Overrides the release name from -f")
+	fs.StringVar(&valuesFile, "values", "", "A values file. Overrides the values from -f")
+	fs.StringVar(&crdValuesFile, "crd-values", "", "A values file merged over the values only when extracting CRDs. Overrides the crdValues from -f")
+	fs.Usage = func() {
+		fmt.Fprintln(errOut, "Usage: manager render [-f controllerwatch.yaml] [--chart CHART] [flags]")
+		fmt.Fprintln(errOut, "Preview the CRDs which would be extracted from a chart, and the kinds which would be watched")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	spec := &controllerv1alpha1.ControllerWatchSpec{}
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(errOut, "error: %v\n", err)
+			return 1
+		}
+		// Both kinds share the same spec, so either can be read as a ControllerWatch
+		controllerWatch := &controllerv1alpha1.ControllerWatch{}
+		if err := yaml.Unmarshal(data, controllerWatch); err != nil {
+			fmt.Fprintf(errOut, "error: failed to parse %s: %v\n", file, err)
+			return 1
+		}
+		spec = controllerWatch.GetSpec()
+	}
+	helmSpec := &spec.HelmControllerSpec
+	for _, override := range []struct {
+		value string
+		field *string
+	}{
+		{chart, &helmSpec.Chart},
+		{version, &helmSpec.Version},
+		{namespace, &helmSpec.Namespace},
+		{releaseName, &helmSpec.ReleaseName},
+	} {
+		if override.value != "" {
+			*override.field = override.value
+		}
+	}
+	for _, override := range []struct {
+		path  string
+		field *string
+	}{
+		{valuesFile, &helmSpec.Values},
+		{crdValuesFile, &helmSpec.CRDValues},
+	} {
+		if override.path == "" {
+			continue
+		}
+		data, err := os.ReadFile(override.path)
+		if err != nil {
+			fmt.Fprintf(errOut, "error: %v\n", err)
+			return 1
+		}
+		*override.field = string(data)
+	}
+	if helmSpec.Chart == "" && (spec.CRDSource == nil || spec.CRDSource.Chart == "") {
+		fmt.Fprintln(errOut, "error: a chart is required, either with --chart or -f")
+		return 2
+	}
+	if helmSpec.Namespace == "" {
+		helmSpec.Namespace = "default"
+	}
+	if helmSpec.ReleaseName == "" {
+		helmSpec.ReleaseName = "kubehoist-render"
+	}
+
+	opts, err := controller.CRDInstallOptions(spec)
+	if err != nil {
+		fmt.Fprintf(errOut, "error: %v\n", err)
+		return 1
+	}
+	helmClient, err := helm.NewOfflineHelmClient(nil)
+	if err != nil {
+		fmt.Fprintf(errOut, "error: %v\n", err)
+		return 1
+	}
+	manifest, err := helmClient.RenderChart(context.Background(), opts)
+	if err != nil {
+		fmt.Fprintf(errOut, "error: %v\n", err)
+		return 1
+	}
+	crds, _, err := helm.ExtractCRDs(manifest)
+	if err != nil {
+		fmt.Fprintf(errOut, "error: %v\n", err)
+		return 1
+	}
+	return printRender(out, spec, helm.CountDocuments(manifest), crds)
+}
+
+// printRender prints the CRDs found in the documents of a rendered chart, and the kinds which would be watched
+func printRender(out io.Writer, spec *controllerv1alpha1.ControllerWatchSpec, documents int, crds []*apiextensionsv1.CustomResourceDefinition) int {
+	fmt.Fprintf(out, "Rendered %d documents, found %d CRDs\n\n", documents, len(crds))
+	if len(crds) == 0 {
+		fmt.Fprintln(out, "WARNING: no CRDs found in the chart. A ControllerWatch for it would fail with NoCRDsFoundInHelmChart")
+		return 1
+	}
+
+	warnings := []string{}
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "CRD\tSCOPE\tSTORAGE VERSION\tCONVERSION")
+	for _, crd := range crds {
+		storageVersion := ""
+		for _, version := range crd.Spec.Versions {
+			if version.Storage {
+				storageVersion = version.Name
+			}
+		}
+		conversion := apiextensionsv1.NoneConverter
+		if crd.Spec.Conversion != nil && crd.Spec.Conversion.Strategy != "" {
+			conversion = crd.Spec.Conversion.Strategy
+		}
+		if conversion == apiextensionsv1.WebhookConverter && len(crd.Spec.Versions) > 1 {
+			warnings = append(warnings, fmt.Sprintf("%s uses a conversion webhook. Watching versions other than %s needs the webhook, "+
+				"which is usually served by the controller and is not available until the controller is installed", crd.Name, storageVersion))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", crd.Name, crd.Spec.Scope, storageVersion, conversion)
+	}
+	if err := w.Flush(); err != nil {
+		return 1
+	}
+
+	watched := 0
+	fmt.Fprintln(out)
+	w = tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tVERSION\tKIND\tWATCHED")
+	for _, crd := range crds {
+		for _, version := range crd.Spec.Versions {
+			gvk := controllerv1alpha1.GroupVersionKind{Group: crd.Spec.Group, Version: version.Name, Kind: crd.Spec.Names.Kind}
+			selected := spec.Trigger.Kinds.Selects(gvk)
+			if selected {
+				watched++
+			}
+			if selected && !version.Served {
+				warnings = append(warnings, fmt.Sprintf("version %s of %s is not served, so it can't be watched", version.Name, crd.Name))
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", gvk.Group, gvk.Version, gvk.Kind, selected)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return 1
+	}
+	if watched == 0 {
+		warnings = append(warnings, "trigger.kinds excludes every kind, so the controller would never be installed by custom resource usage")
+	}
+
+	if len(warnings) > 0 {
+		fmt.Fprintln(out)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(out, "WARNING: %s\n", warning)
+	}
+	return 0
+}
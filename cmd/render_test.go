@@ -0,0 +1,90 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+)
+
+var _ = Describe("runRender", func() {
+	It("counts a List of CRDs as a single document", func() {
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		Expect(runRender([]string{"-f", "testdata/widgets.yaml"}, out, errOut)).To(Equal(0), errOut.String())
+		Expect(out.String()).To(HavePrefix("Rendered 2 documents, found 2 CRDs\n"))
+		Expect(out.String()).To(ContainSubstring("widgets.example.com"))
+		Expect(out.String()).To(MatchRegexp(`example\.com\s+v1\s+Gadget\s+false`))
+		Expect(out.String()).To(ContainSubstring("WARNING: widgets.example.com uses a conversion webhook"))
+	})
+
+	It("lets flags override the ControllerWatch", func() {
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		Expect(runRender([]string{"--chart", "testdata/charts/widgets"}, out, errOut)).To(Equal(0), errOut.String())
+		Expect(out.String()).To(MatchRegexp(`example\.com\s+v1\s+Gadget\s+true`))
+	})
+
+	It("requires a chart", func() {
+		out, errOut := &bytes.Buffer{}, &bytes.Buffer{}
+		Expect(runRender(nil, out, errOut)).To(Equal(2))
+		Expect(errOut.String()).To(ContainSubstring("a chart is required"))
+	})
+})
+
+var _ = Describe("printRender", func() {
+	crd := func(name string, versions ...apiextensionsv1.CustomResourceDefinitionVersion) *apiextensionsv1.CustomResourceDefinition {
+		return &apiextensionsv1.CustomResourceDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: name + "s.example.com"},
+			Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+				Group:    "example.com",
+				Names:    apiextensionsv1.CustomResourceDefinitionNames{Kind: name},
+				Scope:    apiextensionsv1.NamespaceScoped,
+				Versions: versions,
+			},
+		}
+	}
+
+	It("fails without CRDs", func() {
+		out := &bytes.Buffer{}
+		Expect(printRender(out, &controllerv1alpha1.ControllerWatchSpec{}, 3, nil)).To(Equal(1))
+		Expect(out.String()).To(ContainSubstring("Rendered 3 documents, found 0 CRDs"))
+		Expect(out.String()).To(ContainSubstring("NoCRDsFoundInHelmChart"))
+	})
+
+	It("warns about versions which can't be watched", func() {
+		out := &bytes.Buffer{}
+		spec := &controllerv1alpha1.ControllerWatchSpec{}
+		widget := crd("Widget", apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1alpha1"},
+			apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1", Served: true, Storage: true})
+		Expect(printRender(out, spec, 1, []*apiextensionsv1.CustomResourceDefinition{widget})).To(Equal(0))
+		Expect(out.String()).To(ContainSubstring("WARNING: version v1alpha1 of Widgets.example.com is not served"))
+	})
+
+	It("warns when the trigger excludes every kind", func() {
+		out := &bytes.Buffer{}
+		spec := &controllerv1alpha1.ControllerWatchSpec{}
+		spec.Trigger.Kinds.Exclude = []controllerv1alpha1.GroupKind{{Group: "example.com"}}
+		widget := crd("Widget", apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1", Served: true, Storage: true})
+		Expect(printRender(out, spec, 1, []*apiextensionsv1.CustomResourceDefinition{widget})).To(Equal(0))
+		Expect(out.String()).To(ContainSubstring("WARNING: trigger.kinds excludes every kind"))
+	})
+})
@@ -0,0 +1,61 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package budget
+
+import (
+	"sync"
+	"time"
+)
+
+// Admissions are the controllers which were admitted into the hoist budget, but aren't installing yet. They are
+// counted in the next admissions, so that admissions which follow each other quickly can't oversubscribe the budget.
+// The lock is held while deciding on an admission, and every method expects it to be held
+type Admissions struct {
+	sync.Mutex
+	// TTL is how long an admitted controller is counted before it is assumed to have failed to start installing
+	TTL      time.Duration
+	admitted map[string]admission
+}
+
+type admission struct {
+	controller Controller
+	time       time.Time
+}
+
+// NewAdmissions creates an empty set of admissions which are counted for up to ttl
+func NewAdmissions(ttl time.Duration) *Admissions {
+	return &Admissions{TTL: ttl, admitted: map[string]admission{}}
+}
+
+// Admitted returns the controllers which were admitted and are still waiting to be installed. Admissions of
+// controllers which aren't waiting anymore or expired are forgotten
+func (a *Admissions) Admitted(waiting func(name string) bool, now time.Time) []Controller {
+	controllers := []Controller{}
+	for name, admitted := range a.admitted {
+		if !waiting(name) || now.Sub(admitted.time) > a.TTL {
+			delete(a.admitted, name)
+			continue
+		}
+		controllers = append(controllers, admitted.controller)
+	}
+	return controllers
+}
+
+// Admit records that the controller was admitted at the given time
+func (a *Admissions) Admit(controller Controller, now time.Time) {
+	a.admitted[controller.Name] = admission{controller: controller, time: now}
+}
@@ -0,0 +1,45 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package budget
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Admissions", func() {
+	now := time.Now()
+	waiting := func(name string) bool { return name != "installing" }
+
+	It("counts admitted controllers until they stop waiting or expire", func() {
+		admissions := NewAdmissions(time.Minute)
+		admissions.Admit(Controller{Name: "new"}, now)
+		admissions.Admit(Controller{Name: "installing"}, now)
+		admissions.Admit(Controller{Name: "expired"}, now.Add(-2*time.Minute))
+
+		Expect(names(admissions.Admitted(waiting, now))).To(Equal([]string{"new"}))
+		Expect(admissions.Admitted(waiting, now.Add(2*time.Minute))).To(BeEmpty())
+	})
+
+	It("doesn't share admissions between instances", func() {
+		admissions := NewAdmissions(time.Minute)
+		admissions.Admit(Controller{Name: "new"}, now)
+		Expect(NewAdmissions(time.Minute).Admitted(waiting, now)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,143 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package budget decides whether another controller may be hoisted without exceeding the cluster wide hoist budget,
+// and which idle controllers to put to sleep to make room for it
+package budget
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Limits is the hoist budget. A zero value of a limit means it is unlimited
+type Limits struct {
+	// Count is the maximum number of controllers which are hoisted at the same time
+	Count int
+	// Requests is the maximum of the total resource requests of the hoisted controllers
+	Requests corev1.ResourceList
+}
+
+// Enabled returns true if any limit is set
+func (l Limits) Enabled() bool {
+	return l.Count > 0 || len(l.Requests) > 0
+}
+
+// Controller is a controller which is hoisted, or is about to be
+type Controller struct {
+	// Name identifies the controller in messages
+	Name     string
+	Priority int32
+	Requests corev1.ResourceList
+	// Idle is true if the controller may be put to sleep, because none of its custom resources exist
+	Idle bool
+	// LastUsed is when the custom resources of the controller were last used
+	LastUsed time.Time
+}
+
+// Fit decides if incoming can be hoisted next to the running controllers. If it only fits after putting some idle
+// controllers to sleep, these are returned. If it can't fit even then, false is returned with the reason
+func (l Limits) Fit(incoming Controller, running []Controller) ([]Controller, bool, string) {
+	if reason := l.exceeded([]Controller{incoming}); reason != "" {
+		return nil, false, fmt.Sprintf("%s on its own", reason)
+	}
+	// Evict the lowest priority first, and the least recently used of those
+	candidates := []Controller{}
+	for _, controller := range running {
+		if controller.Idle && controller.Priority <= incoming.Priority {
+			candidates = append(candidates, controller)
+		}
+	}
+	slices.SortStableFunc(candidates, func(a, b Controller) int {
+		return cmp.Or(cmp.Compare(a.Priority, b.Priority), a.LastUsed.Compare(b.LastUsed))
+	})
+
+	remaining := slices.Clone(running)
+	evicted := []Controller{}
+	for {
+		reason := l.exceeded(append(slices.Clone(remaining), incoming))
+		if reason == "" {
+			return evicted, true, ""
+		}
+		if len(evicted) == len(candidates) {
+			return nil, false, reason
+		}
+		victim := candidates[len(evicted)]
+		evicted = append(evicted, victim)
+		remaining = slices.DeleteFunc(remaining, func(controller Controller) bool {
+			return controller.Name == victim.Name
+		})
+	}
+}
+
+// exceeded returns why the controllers exceed the limits, or an empty string if they don't
+func (l Limits) exceeded(controllers []Controller) string {
+	reasons := []string{}
+	if l.Count > 0 && len(controllers) > l.Count {
+		reasons = append(reasons, fmt.Sprintf("%d controllers exceed the limit of %d", len(controllers), l.Count))
+	}
+	total := Total(controllers)
+	names := []corev1.ResourceName{}
+	for name := range l.Requests {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		limit := l.Requests[name]
+		if requested, ok := total[name]; ok && requested.Cmp(limit) > 0 {
+			reasons = append(reasons, fmt.Sprintf("%s requests of %s exceed the limit of %s", name, requested.String(), limit.String()))
+		}
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// Total sums the resource requests of the controllers
+func Total(controllers []Controller) corev1.ResourceList {
+	total := corev1.ResourceList{}
+	for _, controller := range controllers {
+		for name, quantity := range controller.Requests {
+			sum := total[name]
+			sum.Add(quantity)
+			total[name] = sum
+		}
+	}
+	return total
+}
+
+// ParseLimits builds the limits from the values of the budget flags. Empty quantities are unlimited
+func ParseLimits(count int, cpu, memory string) (Limits, error) {
+	limits := Limits{Count: count, Requests: corev1.ResourceList{}}
+	for name, value := range map[corev1.ResourceName]string{corev1.ResourceCPU: cpu, corev1.ResourceMemory: memory} {
+		if value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return Limits{}, fmt.Errorf("invalid %s budget: %w", name, err)
+		}
+		limits.Requests[name] = quantity
+	}
+	if len(limits.Requests) == 0 {
+		limits.Requests = nil
+	}
+	return limits, nil
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package budget
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/cheeseandcereal/kubehoist/pkg/helm"
+)
+
+func names(controllers []Controller) []string {
+	result := []string{}
+	for _, controller := range controllers {
+		result = append(result, controller.Name)
+	}
+	return result
+}
+
+func requests(cpu, memory string) corev1.ResourceList {
+	return corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu), corev1.ResourceMemory: resource.MustParse(memory)}
+}
+
+var _ = Describe("Limits", func() {
+	now := time.Now()
+
+	It("fits a controller within the limits without evicting anything", func() {
+		limits := Limits{Count: 2}
+		evict, ok, _ := limits.Fit(Controller{Name: "new"}, []Controller{{Name: "a", Idle: true}})
+		Expect(ok).To(BeTrue())
+		Expect(evict).To(BeEmpty())
+	})
+
+	It("evicts the least recently used idle controllers with the lowest priority first", func() {
+		limits := Limits{Count: 3}
+		running := []Controller{
+			{Name: "busy", LastUsed: now.Add(-3 * time.Hour)},
+			{Name: "recent", Idle: true, LastUsed: now.Add(-time.Minute)},
+			{Name: "old", Idle: true, LastUsed: now.Add(-time.Hour)},
+			{Name: "critical", Idle: true, Priority: 10, LastUsed: now.Add(-2 * time.Hour)},
+		}
+		evict, ok, _ := limits.Fit(Controller{Name: "new"}, running)
+		Expect(ok).To(BeTrue())
+		Expect(names(evict)).To(Equal([]string{"old", "recent"}))
+
+		limits.Count = 1
+		_, ok, reason := limits.Fit(Controller{Name: "new"}, running)
+		Expect(ok).To(BeFalse())
+		Expect(reason).To(ContainSubstring("exceed the limit of 1"))
+
+		evict, ok, _ = limits.Fit(Controller{Name: "new", Priority: 10}, running[1:])
+		Expect(ok).To(BeTrue())
+		Expect(names(evict)).To(Equal([]string{"old", "recent", "critical"}))
+	})
+
+	It("limits the total resource requests", func() {
+		limits, err := ParseLimits(0, "1", "")
+		Expect(err).NotTo(HaveOccurred())
+		running := []Controller{{Name: "a", Idle: true, Requests: requests("600m", "1Gi")}}
+		evict, ok, _ := limits.Fit(Controller{Name: "new", Requests: requests("500m", "1Gi")}, running)
+		Expect(ok).To(BeTrue())
+		Expect(names(evict)).To(Equal([]string{"a"}))
+
+		_, ok, reason := limits.Fit(Controller{Name: "huge", Requests: requests("2", "1Gi")}, nil)
+		Expect(ok).To(BeFalse())
+		Expect(reason).To(Equal("cpu requests of 2 exceed the limit of 1 on its own"))
+	})
+
+	It("sums the requests of the workloads in a manifest", func() {
+		resources, err := helm.ExtractResources(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: controller
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: manager
+        resources:
+          requests:
+            cpu: 100m
+            memory: 128Mi
+      - name: sidecar
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: agent
+spec:
+  template:
+    spec:
+      containers:
+      - name: agent
+        resources:
+          requests:
+            cpu: 50m
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`)
+		Expect(err).NotTo(HaveOccurred())
+		total, err := ManifestRequests(resources)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(total.Cpu().String()).To(Equal("250m"))
+		Expect(total.Memory().String()).To(Equal("256Mi"))
+	})
+})
@@ -0,0 +1,64 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package budget
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ManifestRequests sums the resource requests of the pods which the workloads in a rendered manifest run. Every
+// replica is counted, while DaemonSets are counted once since the number of nodes they run on isn't known.
+// Containers without requests don't count, and neither do Jobs which only run for a while
+func ManifestRequests(resources []*unstructured.Unstructured) (corev1.ResourceList, error) {
+	total := corev1.ResourceList{}
+	for _, obj := range resources {
+		replicas := int64(1)
+		templatePath := []string{"spec", "template"}
+		switch obj.GetKind() {
+		case "Deployment", "StatefulSet", "ReplicaSet":
+			if value, found, err := unstructured.NestedInt64(obj.Object, "spec", "replicas"); err == nil && found {
+				replicas = value
+			}
+		case "DaemonSet":
+		case "Pod":
+			templatePath = nil
+		default:
+			continue
+		}
+		podSpec := &corev1.PodSpec{}
+		raw, found, err := unstructured.NestedMap(obj.Object, append(templatePath, "spec")...)
+		if err != nil || !found {
+			continue
+		}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, podSpec); err != nil {
+			return nil, fmt.Errorf("invalid pod spec in %s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+		for _, container := range podSpec.Containers {
+			for name, quantity := range container.Resources.Requests {
+				quantity.Mul(replicas)
+				sum := total[name]
+				sum.Add(quantity)
+				total[name] = sum
+			}
+		}
+	}
+	return total, nil
+}
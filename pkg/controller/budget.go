@@ -0,0 +1,289 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/budget"
+	"github.com/cheeseandcereal/kubehoist/pkg/helm"
+	"github.com/cheeseandcereal/kubehoist/pkg/schedule"
+	"github.com/cheeseandcereal/kubehoist/pkg/statusupdate"
+)
+
+// budgetFieldManager is the field manager which requests the sleep of evicted controllers
+const budgetFieldManager = "kubehoist-budget"
+
+// AdmissionTTL is how long an admitted controller is counted in the hoist budget before its install shows up in the
+// cache. After that, the install is assumed to have failed to start
+const AdmissionTTL = time.Minute
+
+// evictionGracePeriod is how long a controller is protected from being put to sleep after it was installed, so that
+// it isn't evicted before its custom resources were seen
+const evictionGracePeriod = 10 * time.Minute
+
+// reconcileBudget admits the controller of a ControllerWatch into the hoist budget, and returns its resource requests
+// if it may be installed now. Otherwise idle controllers are put to sleep to make room for it, or it is queued until
+// there is room. The BudgetExceeded condition says which
+func (r *ControllerWatchReconciler) reconcileBudget(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, log logr.Logger) (corev1.ResourceList, bool, error) {
+	if r.Budget == nil || !r.Budget.Enabled() {
+		return nil, true, nil
+	}
+	// Rendering may pull the chart, so it happens before the budget is locked
+	requests, err := r.controllerRequests(ctx, controllerWatchResource, log)
+	if err != nil {
+		// The install fails the same way, which is reported there
+		log.Error(err, "Failed to compute the resource requests of the controller")
+		return nil, true, nil
+	}
+	incoming := budget.Controller{Name: budgetName(controllerWatchResource), Priority: budgetPriority(controllerWatchResource), Requests: requests}
+	evict, fits, reason, byName, err := r.admit(ctx, incoming, controllerWatchResource)
+	if err != nil {
+		return nil, false, err
+	}
+	if !fits {
+		message := "Queued because the hoist budget is exceeded: " + reason
+		if !hasCondition(controllerWatchResource, controllerv1alpha1.ConditionBudgetExceeded, controllerv1alpha1.ReasonBudgetExceeded) {
+			log.Info("Queueing controller because the hoist budget is exceeded", "reason", reason)
+			r.Recorder.Event(controllerWatchResource, corev1.EventTypeWarning, "BudgetExceeded", message)
+		}
+		return nil, false, r.setBudgetCondition(ctx, controllerWatchResource, metav1.ConditionTrue, controllerv1alpha1.ReasonBudgetExceeded, message)
+	}
+	if len(evict) > 0 {
+		names := []string{}
+		for _, victim := range evict {
+			names = append(names, victim.Name)
+			if err := r.evict(ctx, byName[victim.Name], controllerWatchResource, log); err != nil {
+				return nil, false, err
+			}
+		}
+		message := "Waiting for idle controllers to be put to sleep: " + strings.Join(names, ", ")
+		return nil, false, r.setBudgetCondition(ctx, controllerWatchResource, metav1.ConditionTrue, controllerv1alpha1.ReasonEvictingControllers, message)
+	}
+	return requests, true, r.setBudgetCondition(ctx, controllerWatchResource, metav1.ConditionFalse, controllerv1alpha1.ReasonWithinBudget, "The controller fits in the hoist budget")
+}
+
+// admit fits incoming into the hoist budget next to the controllers which are Installing or Installed, and the ones
+// which were admitted but don't show up in the cache yet. If it fits without evictions, it is recorded as admitted
+func (r *ControllerWatchReconciler) admit(ctx context.Context, incoming budget.Controller, controllerWatchResource controllerv1alpha1.ControllerWatchObject) ([]budget.Controller, bool, string, map[string]controllerv1alpha1.ControllerWatchObject, error) {
+	r.BudgetAdmissions.Lock()
+	defer r.BudgetAdmissions.Unlock()
+	controllerWatches, err := r.listAllControllerWatches(ctx)
+	if err != nil {
+		return nil, false, "", nil, err
+	}
+	running := []budget.Controller{}
+	byName := map[string]controllerv1alpha1.ControllerWatchObject{}
+	pending := map[string]bool{}
+	for _, controllerWatch := range controllerWatches {
+		status := controllerWatch.GetStatus().ControllerInstallationStatus
+		name := budgetName(controllerWatch)
+		if status == controllerv1alpha1.ControllerInstallationStatusPending {
+			pending[name] = true
+		}
+		if name == incoming.Name || (status != controllerv1alpha1.ControllerInstallationStatusInstalling && status != controllerv1alpha1.ControllerInstallationStatusInstalled) {
+			continue
+		}
+		byName[name] = controllerWatch
+		running = append(running, budget.Controller{
+			Name:     name,
+			Priority: budgetPriority(controllerWatch),
+			Requests: controllerWatch.GetStatus().ResourceRequests,
+			Idle:     idle(controllerWatch, time.Now()) && mayEvict(controllerWatchResource, controllerWatch),
+			LastUsed: lastUsed(controllerWatch),
+		})
+	}
+	// Admissions are made while Pending, so any other status in the cache means the cache caught up
+	waiting := func(name string) bool { return pending[name] }
+	for _, admitted := range r.BudgetAdmissions.Admitted(waiting, time.Now()) {
+		if admitted.Name != incoming.Name {
+			running = append(running, admitted)
+		}
+	}
+
+	evict, fits, reason := r.Budget.Fit(incoming, running)
+	if fits && len(evict) == 0 {
+		r.BudgetAdmissions.Admit(incoming, time.Now())
+	}
+	return evict, fits, reason, byName, nil
+}
+
+// controllerRequests renders the chart of the controller and sums the resource requests of its workloads
+func (r *ControllerWatchReconciler) controllerRequests(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, log logr.Logger) (corev1.ResourceList, error) {
+	helmInstallOpts, err := r.getHelmInstallOptions(controllerWatchResource, log)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := r.HelmClient.RenderChart(ctx, helmInstallOpts)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := helm.ExtractResources(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return budget.ManifestRequests(resources)
+}
+
+// evict puts an idle controller to sleep to make room for another one. The sleep is requested with the sleep
+// annotation, so that it is handled by the reconciler of its own kind
+func (r *ControllerWatchReconciler) evict(ctx context.Context, victim, controllerWatchResource controllerv1alpha1.ControllerWatchObject, log logr.Logger) error {
+	if _, ok := victim.GetAnnotations()[controllerv1alpha1.SleepAnnotation]; ok {
+		return nil
+	}
+	log.Info("Putting idle controller to sleep to make room in the hoist budget", "evicted", budgetName(victim))
+	r.Recorder.Eventf(victim, corev1.EventTypeNormal, "Evicted", "Putting controller to sleep to make room in the hoist budget for %s", budgetName(controllerWatchResource))
+	patch := client.MergeFrom(victim.DeepCopyObject().(client.Object))
+	annotations := victim.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[controllerv1alpha1.SleepAnnotation] = controllerv1alpha1.ManualRequestValue
+	victim.SetAnnotations(annotations)
+	return r.Patch(ctx, victim, patch, client.FieldOwner(budgetFieldManager))
+}
+
+func (r *ControllerWatchReconciler) setBudgetCondition(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, status metav1.ConditionStatus, reason, message string) error {
+	return r.updateStatus(ctx, controllerWatchResource, statusupdate.SetCondition(metav1.Condition{
+		Type:               controllerv1alpha1.ConditionBudgetExceeded,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: controllerWatchResource.GetGeneration(),
+	}))
+}
+
+// listAllControllerWatches lists the ControllerWatches and NamespacedControllerWatches in all namespaces, since they
+// share the hoist budget
+func (r *ControllerWatchReconciler) listAllControllerWatches(ctx context.Context) ([]controllerv1alpha1.ControllerWatchObject, error) {
+	controllerWatches := []controllerv1alpha1.ControllerWatchObject{}
+	clusterList := &controllerv1alpha1.ControllerWatchList{}
+	if err := r.List(ctx, clusterList); err != nil {
+		return nil, err
+	}
+	for i := range clusterList.Items {
+		controllerWatches = append(controllerWatches, &clusterList.Items[i])
+	}
+	namespacedList := &controllerv1alpha1.NamespacedControllerWatchList{}
+	if err := r.List(ctx, namespacedList); err != nil {
+		return nil, err
+	}
+	for i := range namespacedList.Items {
+		controllerWatches = append(controllerWatches, &namespacedList.Items[i])
+	}
+	return controllerWatches, nil
+}
+
+// budgetWaiters maps any change of a ControllerWatch of either kind to the ControllerWatches which are waiting for
+// room in the hoist budget, since the change may have made room
+func (r *ControllerWatchReconciler) budgetWaiters(ctx context.Context, obj client.Object) []reconcile.Request {
+	controllerWatches, err := r.listControllerWatches(ctx, "")
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list ControllerWatches waiting for the hoist budget")
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, controllerWatch := range controllerWatches {
+		if meta.IsStatusConditionTrue(controllerWatch.GetStatus().Conditions, controllerv1alpha1.ConditionBudgetExceeded) &&
+			controllerWatch.GetStatus().ControllerInstallationStatus == controllerv1alpha1.ControllerInstallationStatusPending {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(controllerWatch)})
+		}
+	}
+	return requests
+}
+
+// budgetPriority returns the priority of a controller in the hoist budget. Tenants can lower the priority of a
+// NamespacedControllerWatch, but not raise it above the default to protect it from being put to sleep
+func budgetPriority(controllerWatch controllerv1alpha1.ControllerWatchObject) int32 {
+	if controllerWatch.GetNamespace() != "" {
+		return min(controllerWatch.GetSpec().Priority, 0)
+	}
+	return controllerWatch.GetSpec().Priority
+}
+
+// mayEvict returns true if the controller of incoming may put the one of victim to sleep. A NamespacedControllerWatch
+// may only put controllers of its own namespace to sleep
+func mayEvict(incoming, victim controllerv1alpha1.ControllerWatchObject) bool {
+	return incoming.GetNamespace() == "" || incoming.GetNamespace() == victim.GetNamespace()
+}
+
+// idle returns true if a controller may be put to sleep, because none of its custom resources exist, it is not in
+// a warm window of its schedule, and it was installed long enough ago for its custom resources to have been seen
+func idle(controllerWatch controllerv1alpha1.ControllerWatchObject, now time.Time) bool {
+	status := controllerWatch.GetStatus()
+	if status.ControllerInstallationStatus != controllerv1alpha1.ControllerInstallationStatusInstalled {
+		return false
+	}
+	if progress := status.InstallProgress; progress != nil {
+		installed := progress.QueuedAt.Time
+		if progress.FinishedAt != nil {
+			installed = progress.FinishedAt.Time
+		}
+		if now.Sub(installed) < evictionGracePeriod {
+			return false
+		}
+	}
+	if state, _, err := schedule.Evaluate(controllerWatch.GetSpec().Schedule, now); err == nil && state == schedule.StateWarm {
+		return false
+	}
+	for _, usage := range status.Usage {
+		if usage.Count > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// lastUsed returns when the custom resources of a controller were last seen or triggered it
+func lastUsed(controllerWatch controllerv1alpha1.ControllerWatchObject) time.Time {
+	status := controllerWatch.GetStatus()
+	last := time.Time{}
+	if status.LastTriggered != nil {
+		last = status.LastTriggered.Time
+	}
+	for _, usage := range status.Usage {
+		if usage.LastSeen != nil && usage.LastSeen.After(last) {
+			last = usage.LastSeen.Time
+		}
+	}
+	return last
+}
+
+func hasCondition(controllerWatch controllerv1alpha1.ControllerWatchObject, conditionType, reason string) bool {
+	condition := meta.FindStatusCondition(controllerWatch.GetStatus().Conditions, conditionType)
+	return condition != nil && condition.Status == metav1.ConditionTrue && condition.Reason == reason
+}
+
+// budgetName identifies a ControllerWatch of either kind in the hoist budget
+func budgetName(controllerWatch controllerv1alpha1.ControllerWatchObject) string {
+	if controllerWatch.GetNamespace() != "" {
+		return fmt.Sprintf("ncw/%s/%s", controllerWatch.GetNamespace(), controllerWatch.GetName())
+	}
+	return "cw/" + controllerWatch.GetName()
+}
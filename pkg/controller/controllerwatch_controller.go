@@ -18,32 +18,72 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
 	"time"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	"sigs.k8s.io/yaml"
 
+	"helm.sh/helm/v3/pkg/chartutil"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
 	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/budget"
 	"github.com/cheeseandcereal/kubehoist/pkg/helm"
+	"github.com/cheeseandcereal/kubehoist/pkg/installer"
+	"github.com/cheeseandcereal/kubehoist/pkg/installpool"
+	"github.com/cheeseandcereal/kubehoist/pkg/postrenderer"
+	"github.com/cheeseandcereal/kubehoist/pkg/statusupdate"
 	"github.com/cheeseandcereal/kubehoist/pkg/watcher"
 	"github.com/go-logr/logr"
 )
 
-// ControllerWatchReconciler reconciles a ControllerWatch object
+// ControllerWatchReconciler reconciles a ControllerWatch object, or a NamespacedControllerWatch object if Namespaced is set
 type ControllerWatchReconciler struct {
 	client.Client
-	Manager        manager.Manager
-	HelmClient     *helm.HelmClient
-	customWatchers map[string]*watcher.GenericWatcher
+	Manager    manager.Manager
+	HelmClient *helm.HelmClient
+	Recorder   record.EventRecorder
+	// InstallPool runs the helm installs in the background, so that a slow install doesn't block reconciling
+	InstallPool *installpool.Pool
+	// InstallerJobs if set runs the helm installs in Kubernetes Jobs instead of in the manager process
+	InstallerJobs *installer.JobRunner
+	// Namespaced if true will reconcile NamespacedControllerWatch resources instead of ControllerWatch resources
+	Namespaced bool
+	// Budget if set limits how many controllers are hoisted at the same time, and their total resource requests
+	Budget *budget.Limits
+	// BudgetAdmissions are the admissions into the hoist budget, which are shared by the reconcilers of both kinds
+	BudgetAdmissions *budget.Admissions
+	// AllowedNamespacedCRDGroups is the admin approved list of CRD groups which a NamespacedControllerWatch may install
+	AllowedNamespacedCRDGroups []string
+	customWatchers             map[string]*watcher.GenericWatcher
+	watchersLock               sync.Mutex
+	// recovered is closed once recoverOnStartup finished, and reconciling waits for it
+	recovered chan struct{}
+	// installEvents enqueues a reconcile when an install in the InstallPool starts or finishes
+	installEvents chan event.GenericEvent
 }
 
 // +kubebuilder:rbac:groups=controller.kubehoist.io,resources=controllerwatches,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=controller.kubehoist.io,resources=controllerwatches/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=controller.kubehoist.io,resources=controllerwatches/finalizers,verbs=update
+// +kubebuilder:rbac:groups=controller.kubehoist.io,resources=namespacedcontrollerwatches,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=controller.kubehoist.io,resources=namespacedcontrollerwatches/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=controller.kubehoist.io,resources=namespacedcontrollerwatches/finalizers,verbs=update
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=impersonate
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch;create;update;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -57,8 +97,17 @@ type ControllerWatchReconciler struct {
 func (r *ControllerWatchReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
-	var controllerWatchResource controllerv1alpha1.ControllerWatch
-	if err := r.Get(ctx, req.NamespacedName, &controllerWatchResource); err != nil {
+	if r.recovered != nil {
+		// Don't race the recovery of the state which a previous leader left behind
+		select {
+		case <-r.recovered:
+		case <-ctx.Done():
+			return ctrl.Result{}, ctx.Err()
+		}
+	}
+
+	controllerWatchResource := r.newControllerWatch()
+	if err := r.Get(ctx, req.NamespacedName, controllerWatchResource); err != nil {
 		log.Error(err, "Unable to fetch controller watch custom resource")
 		// we'll ignore not-found errors, since they can't be fixed by an immediate
 		// requeue (we'll need to wait for a new notification), and we can get them
@@ -66,66 +115,133 @@ func (r *ControllerWatchReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	if controllerWatchResource.Status.ControllerInstallationStatus == controllerv1alpha1.ControllerInstallationStatusInstalled {
-		// This controller has already been installed. Nothing to do
-		// TODO: Add any sort of health checks or update logic
-		return ctrl.Result{}, nil
+	if controllerWatchResource.GetSpec().DryRun {
+		// Only plan what would happen, without changing anything in the cluster
+		return ctrl.Result{}, r.reconcilePlan(ctx, controllerWatchResource, log)
 	}
 
-	if controllerWatchResource.Status.CRDsInstallationStatus != controllerv1alpha1.CRDInstallationStatusInstalled {
-		err := r.installCRDs(ctx, &controllerWatchResource, log)
+	if done, err := r.handleManualRequests(ctx, controllerWatchResource, log); done || err != nil {
 		return ctrl.Result{}, err
 	}
 
-	if len(controllerWatchResource.Status.InstalledCRDs) > 0 {
-		// Make sure we have a registered watchers for all the CRDs
-		for _, crd := range controllerWatchResource.Status.InstalledCRDs {
-			if _, ok := r.customWatchers[crd.String()]; !ok {
-				log.Info("Creating watcher for CRD", "crd", crd)
-				watcher := &watcher.GenericWatcher{
-					Client:          r.Manager.GetClient(),
-					GVK:             crd.ToSchemaGVK(),
-					ControllerWatch: req.NamespacedName,
-				}
-				// Start the watcher
-				if err := watcher.SetupWithManager(r.Manager); err != nil {
-					log.Error(err, "Failed to setup watcher for CRD", "crd", crd)
-					return ctrl.Result{}, err
-				}
-				r.customWatchers[crd.String()] = watcher
+	result := ctrl.Result{}
+	nextScheduleBoundary, done, err := r.reconcileSchedule(ctx, controllerWatchResource, log)
+	if !nextScheduleBoundary.IsZero() {
+		// Make sure we are reconciled again when the next schedule window starts or ends
+		result.RequeueAfter = time.Until(nextScheduleBoundary)
+	}
+	if done || err != nil {
+		return result, err
+	}
+
+	status := controllerWatchResource.GetStatus()
+	if status.CRDsInstallationStatus == controllerv1alpha1.CRDInstallationStatusInstalled && status.ObservedGeneration != controllerWatchResource.GetGeneration() {
+		// The spec changed (i.e. a chart version bump), so the CRDs need to be checked and applied again
+		if status.ObservedGeneration != 0 && status.ControllerInstallationStatus == controllerv1alpha1.ControllerInstallationStatusInstalled {
+			// Upgrade the installed controller as well once the CRDs are applied
+			if err := r.updateControllerInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.ControllerInstallationStatusPending); err != nil {
+				return result, err
 			}
 		}
+		err := r.installCRDs(ctx, controllerWatchResource, log)
+		return result, err
+	}
+	if status.CRDsInstallationStatus == controllerv1alpha1.CRDInstallationStatusInstalled {
+		if err := r.reconcileStorageVersionMigrations(ctx, controllerWatchResource, log); err != nil {
+			return result, err
+		}
+	}
+	if status.ControllerInstallationStatus == controllerv1alpha1.ControllerInstallationStatusInstalled {
+		// This controller has already been installed. Nothing to do
+		// TODO: Add any sort of health checks or update logic
+		return result, nil
 	}
 
-	if controllerWatchResource.Status.ControllerInstallationStatus == controllerv1alpha1.ControllerInstallationStatusPending {
+	if status.CRDsInstallationStatus != controllerv1alpha1.CRDInstallationStatusInstalled {
+		err := r.installCRDs(ctx, controllerWatchResource, log)
+		return result, err
+	}
+
+	if err := r.ensureWatchers(controllerWatchResource, log); err != nil {
+		return result, err
+	}
+
+	if status.ControllerInstallationStatus == controllerv1alpha1.ControllerInstallationStatusPending ||
+		status.ControllerInstallationStatus == controllerv1alpha1.ControllerInstallationStatusInstalling {
 		// Trigger installation of helm chart if the status of this controller installation is pending
-		err := r.installController(ctx, &controllerWatchResource, log)
-		return ctrl.Result{}, err
+		err := r.reconcileInstall(ctx, controllerWatchResource, log)
+		return result, err
 	}
 
-	return ctrl.Result{}, nil
+	return result, nil
 }
 
-func (r *ControllerWatchReconciler) installCRDs(ctx context.Context, controllerWatchResource *controllerv1alpha1.ControllerWatch, log logr.Logger) error {
-	log.Info("Installing CRDs from chart", "chart", controllerWatchResource.Spec.HelmControllerSpec.Chart)
-	helmInstallOpts, err := getHelmInstallOptions(controllerWatchResource, log)
+// ensureWatchers makes sure there is a registered watcher for every installed CRD which can trigger the installation
+func (r *ControllerWatchReconciler) ensureWatchers(controllerWatchResource controllerv1alpha1.ControllerWatchObject, log logr.Logger) error {
+	r.watchersLock.Lock()
+	defer r.watchersLock.Unlock()
+	for _, crd := range controllerWatchResource.GetStatus().InstalledCRDs {
+		if !controllerWatchResource.GetSpec().Trigger.Kinds.Selects(crd) {
+			// CRDs which can't trigger the installation don't need a watcher
+			continue
+		}
+		key := crd.String()
+		if r.Namespaced {
+			key = controllerWatchResource.GetNamespace() + "/" + key
+		}
+		if _, ok := r.customWatchers[key]; ok {
+			continue
+		}
+		log.Info("Creating watcher for CRD", "crd", crd)
+		watcher := &watcher.GenericWatcher{
+			Client:          r.Manager.GetClient(),
+			GVK:             crd.ToSchemaGVK(),
+			ControllerWatch: client.ObjectKeyFromObject(controllerWatchResource),
+		}
+		if r.Namespaced {
+			watcher.Namespace = controllerWatchResource.GetNamespace()
+		}
+		// Start the watcher
+		if err := watcher.SetupWithManager(r.Manager); err != nil {
+			log.Error(err, "Failed to setup watcher for CRD", "crd", crd)
+			return err
+		}
+		r.customWatchers[key] = watcher
+	}
+	return nil
+}
+
+func (r *ControllerWatchReconciler) installCRDs(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, log logr.Logger) error {
+	observed := statusupdate.SetObservedGeneration(controllerWatchResource.GetGeneration())
+	helmInstallOpts, err := r.getCRDInstallOptions(controllerWatchResource, log)
 	if err != nil {
-		err = r.updateCRDInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.CRDInstallationStatusInvalidHelmChartValues)
-		return err
+		r.Recorder.Eventf(controllerWatchResource, corev1.EventTypeWarning, "InvalidHelmChartValues", "Invalid helm install options: %v", err)
+		return r.updateCRDInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.CRDInstallationStatusInvalidHelmChartValues, observed)
+	}
+	log.Info("Installing CRDs from chart", "chart", helmInstallOpts.ChartName)
+	installedCRDs, findings, err := r.HelmClient.InstallChartCRDs(ctx, helmInstallOpts, r.Client)
+	mutations := []statusupdate.Mutation{observed}
+	if condition := crdUpgradeCondition(controllerWatchResource, findings); condition != nil {
+		mutations = append(mutations, statusupdate.SetCondition(*condition))
+	}
+	if errors.Is(err, helm.ErrUnsafeCRDUpgrade) {
+		log.Error(err, "Applying the CRDs from the helm chart is unsafe", "findings", findings)
+		r.Recorder.Eventf(controllerWatchResource, corev1.EventTypeWarning, "CRDUpgradeBlocked", "Not applying CRDs: %s", joinFindings(findings))
+		return r.updateCRDInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.CRDInstallationStatusUpgradeBlocked, mutations...)
+	}
+	if errors.Is(err, helm.ErrCRDNotAllowed) {
+		log.Error(err, "Helm chart contains CRDs which are not allowed")
+		return r.updateCRDInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.CRDInstallationStatusNotAllowed, mutations...)
 	}
-	installedCRDs, err := r.HelmClient.InstallChartCRDs(ctx, helmInstallOpts, r.Client)
 	if err != nil {
 		log.Error(err, "Failed to template helm chart")
-		err = r.updateCRDInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.CRDInstallationStatusHelmChartFailed)
-		return err
+		return r.updateCRDInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.CRDInstallationStatusHelmChartFailed, mutations...)
 	}
 	if len(installedCRDs) == 0 {
 		log.Error(err, "No CRDs found in helm chart")
-		err = r.updateCRDInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.CRDInstallationStatusNoCRDsFound)
-		return err
+		return r.updateCRDInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.CRDInstallationStatusNoCRDsFound, mutations...)
 	}
 	log.Info("Successfully installed CRDs from helm chart", "crds", installedCRDs)
-	// TODO: Register new CRDs to a new watcher somewhere
 	installed := []controllerv1alpha1.GroupVersionKind{}
 	for _, crd := range installedCRDs {
 		installed = append(installed, controllerv1alpha1.GroupVersionKind{
@@ -134,42 +250,21 @@ func (r *ControllerWatchReconciler) installCRDs(ctx context.Context, controllerW
 			Kind:    crd.Kind,
 		})
 	}
-	controllerWatchResource.Status.InstalledCRDs = installed
-	err = r.updateCRDInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.CRDInstallationStatusInstalled)
-	return err
-}
-
-func (r *ControllerWatchReconciler) installController(ctx context.Context, controllerWatchResource *controllerv1alpha1.ControllerWatch, log logr.Logger) error {
-	log.Info("Installing Chart", "chart", controllerWatchResource.Spec.HelmControllerSpec.Chart)
-	helmInstallOpts, err := getHelmInstallOptions(controllerWatchResource, log)
-	if err != nil {
-		err = r.updateControllerInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.ControllerInstallationStatusInstallFailed)
-		return err
-	}
-	err = r.HelmClient.InstallChart(ctx, helmInstallOpts)
-	if err != nil {
-		log.Error(err, "Failed to install helm chart")
-		err = r.updateControllerInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.ControllerInstallationStatusInstallFailed)
-		return err
-	}
-	log.Info("Successfully installed helm chart")
-	err = r.updateControllerInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.ControllerInstallationStatusInstalled)
-	return err
+	mutations = append(mutations, statusupdate.SetInstalledCRDs(installed))
+	return r.updateCRDInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.CRDInstallationStatusInstalled, mutations...)
 }
 
-func (r *ControllerWatchReconciler) updateCRDInstallationStatus(ctx context.Context, controllerWatchResource *controllerv1alpha1.ControllerWatch, status controllerv1alpha1.CRDInstallationStatus) error {
-	controllerWatchResource.Status.CRDsInstallationStatus = status
-	return r.updateStatus(ctx, controllerWatchResource)
+func (r *ControllerWatchReconciler) updateCRDInstallationStatus(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, status controllerv1alpha1.CRDInstallationStatus, mutations ...statusupdate.Mutation) error {
+	return r.updateStatus(ctx, controllerWatchResource, append(mutations, statusupdate.SetCRDsInstallationStatus(status))...)
 }
 
-func (r *ControllerWatchReconciler) updateControllerInstallationStatus(ctx context.Context, controllerWatchResource *controllerv1alpha1.ControllerWatch, status controllerv1alpha1.ControllerInstallationStatus) error {
-	controllerWatchResource.Status.ControllerInstallationStatus = status
-	return r.updateStatus(ctx, controllerWatchResource)
+func (r *ControllerWatchReconciler) updateControllerInstallationStatus(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, status controllerv1alpha1.ControllerInstallationStatus, mutations ...statusupdate.Mutation) error {
+	return r.updateStatus(ctx, controllerWatchResource, append(mutations, statusupdate.SetControllerInstallationStatus(status))...)
 }
 
-func (r *ControllerWatchReconciler) updateStatus(ctx context.Context, controllerWatchResource *controllerv1alpha1.ControllerWatch) error {
-	controllerWatchResource.Status.LastUpdated = &metav1.Time{Time: time.Now()}
-	if err := r.Status().Update(ctx, controllerWatchResource); err != nil {
+// updateStatus writes the changes of the mutations to the status, retrying on conflicts
+func (r *ControllerWatchReconciler) updateStatus(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, mutations ...statusupdate.Mutation) error {
+	if err := statusupdate.Patch(ctx, r.Client, controllerWatchResource, mutations...); err != nil {
 		log.FromContext(ctx).Error(err, "could not update ControllerWatch status")
 		return err
 	}
@@ -181,31 +276,209 @@ func (r *ControllerWatchReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	if r.customWatchers == nil {
 		r.customWatchers = map[string]*watcher.GenericWatcher{}
 	}
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&controllerv1alpha1.ControllerWatch{}).
-		Named("controllerwatch").
-		Complete(r)
+	if r.BudgetAdmissions == nil {
+		r.BudgetAdmissions = budget.NewAdmissions(AdmissionTTL)
+	}
+	r.installEvents = make(chan event.GenericEvent, 100)
+	r.recovered = make(chan struct{})
+	// Runnables which aren't LeaderElectionRunnables only run on the leader
+	if err := mgr.Add(manager.RunnableFunc(r.recoverOnStartup)); err != nil {
+		return err
+	}
+	name := "controllerwatch"
+	if r.Namespaced {
+		name = "namespacedcontrollerwatch"
+	}
+	builder := ctrl.NewControllerManagedBy(mgr).
+		For(r.newControllerWatch()).
+		Watches(r.newControllerWatch(), handler.EnqueueRequestsFromMapFunc(r.dependentsOf)).
+		WatchesRawSource(source.Channel(r.installEvents, &handler.EnqueueRequestForObject{}))
+	if r.Budget != nil && r.Budget.Enabled() {
+		// Controllers of either kind going to sleep can make room for the ones which are queued
+		builder = builder.
+			Watches(&controllerv1alpha1.ControllerWatch{}, handler.EnqueueRequestsFromMapFunc(r.budgetWaiters)).
+			Watches(&controllerv1alpha1.NamespacedControllerWatch{}, handler.EnqueueRequestsFromMapFunc(r.budgetWaiters))
+	}
+	return builder.Named(name).Complete(r)
 }
 
-func getHelmInstallOptions(controllerWatchResource *controllerv1alpha1.ControllerWatch, log logr.Logger) (helm.InstallOptions, error) {
-	values := map[string]interface{}{}
-	if controllerWatchResource.Spec.HelmControllerSpec.Values != "" {
-		err := yaml.Unmarshal([]byte(controllerWatchResource.Spec.HelmControllerSpec.Values), &values)
-		if err != nil {
-			log.Error(err, "Failed to unmarshal values")
+func (r *ControllerWatchReconciler) newControllerWatch() controllerv1alpha1.ControllerWatchObject {
+	if r.Namespaced {
+		return &controllerv1alpha1.NamespacedControllerWatch{}
+	}
+	return &controllerv1alpha1.ControllerWatch{}
+}
+
+func (r *ControllerWatchReconciler) listControllerWatches(ctx context.Context, namespace string) ([]controllerv1alpha1.ControllerWatchObject, error) {
+	controllerWatches := []controllerv1alpha1.ControllerWatchObject{}
+	if r.Namespaced {
+		list := &controllerv1alpha1.NamespacedControllerWatchList{}
+		if err := r.List(ctx, list, client.InNamespace(namespace)); err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			controllerWatches = append(controllerWatches, &list.Items[i])
+		}
+		return controllerWatches, nil
+	}
+	list := &controllerv1alpha1.ControllerWatchList{}
+	if err := r.List(ctx, list); err != nil {
+		return nil, err
+	}
+	for i := range list.Items {
+		controllerWatches = append(controllerWatches, &list.Items[i])
+	}
+	return controllerWatches, nil
+}
+
+func (r *ControllerWatchReconciler) getHelmInstallOptions(controllerWatchResource controllerv1alpha1.ControllerWatchObject, log logr.Logger) (helm.InstallOptions, error) {
+	opts, err := HelmInstallOptions(controllerWatchResource.GetSpec())
+	if err != nil {
+		log.Error(err, "Invalid helm install options")
+		return helm.InstallOptions{}, err
+	}
+	if r.Namespaced {
+		if err := RestrictNamespacedInstallOptions(controllerWatchResource.GetNamespace(), r.AllowedNamespacedCRDGroups, &opts); err != nil {
+			log.Error(err, "Invalid helm install options for a NamespacedControllerWatch")
 			return helm.InstallOptions{}, err
 		}
 	}
+	return opts, nil
+}
+
+// getCRDInstallOptions returns the helm install options used to render the chart for extracting CRDs
+func (r *ControllerWatchReconciler) getCRDInstallOptions(controllerWatchResource controllerv1alpha1.ControllerWatchObject, log logr.Logger) (helm.InstallOptions, error) {
+	if r.Namespaced {
+		// The allowlist has to be checked against the CRDs of the chart which is really installed
+		spec := controllerWatchResource.GetSpec()
+		if spec.CRDSource != nil || spec.HelmControllerSpec.CRDValues != "" {
+			err := errors.New("crdSource and helmSpec.crdValues are not allowed for a NamespacedControllerWatch")
+			log.Error(err, "Invalid helm install options")
+			return helm.InstallOptions{}, err
+		}
+	}
+	opts, err := CRDInstallOptions(controllerWatchResource.GetSpec())
+	if err != nil {
+		log.Error(err, "Invalid helm install options")
+		return helm.InstallOptions{}, err
+	}
+	if r.Namespaced {
+		if err := RestrictNamespacedInstallOptions(controllerWatchResource.GetNamespace(), r.AllowedNamespacedCRDGroups, &opts); err != nil {
+			log.Error(err, "Invalid helm install options for a NamespacedControllerWatch")
+			return helm.InstallOptions{}, err
+		}
+	}
+	return opts, nil
+}
+
+// RestrictNamespacedInstallOptions limits what the chart of a NamespacedControllerWatch in namespace may install.
+// The chart is always installed impersonating a service account of that namespace, and may only contain CRDs of the
+// allowed groups
+func RestrictNamespacedInstallOptions(namespace string, allowedCRDGroups []string, opts *helm.InstallOptions) error {
+	// A NamespacedControllerWatch may only install into its own namespace
+	if opts.Namespace != namespace {
+		return fmt.Errorf("namespace %q must match the namespace of the NamespacedControllerWatch %q", opts.Namespace, namespace)
+	}
+	// Otherwise the chart would be installed with the cluster wide permissions of kubehoist
+	if opts.ServiceAccountName == "" {
+		return fmt.Errorf("serviceAccountName must be set to a service account in namespace %q", namespace)
+	}
+	opts.CreateNamespace = false
+	opts.CRDAllowed = func(crd *apiextensionsv1.CustomResourceDefinition) bool {
+		return slices.Contains(allowedCRDGroups, crd.Spec.Group)
+	}
+	return nil
+}
+
+// HelmInstallOptions returns the helm install options for the controller chart of a ControllerWatch spec
+func HelmInstallOptions(spec *controllerv1alpha1.ControllerWatchSpec) (helm.InstallOptions, error) {
+	helmSpec := spec.HelmControllerSpec
+	values, err := parseValues(helmSpec.Values)
+	if err != nil {
+		return helm.InstallOptions{}, fmt.Errorf("invalid values: %w", err)
+	}
 	createNamespace := false
-	if controllerWatchResource.Spec.HelmControllerSpec.CreateNamespace != nil {
-		createNamespace = *controllerWatchResource.Spec.HelmControllerSpec.CreateNamespace
-	}
-	return helm.InstallOptions{
-		ChartName:       controllerWatchResource.Spec.HelmControllerSpec.Chart,
-		Namespace:       controllerWatchResource.Spec.HelmControllerSpec.Namespace,
-		ReleaseName:     controllerWatchResource.Spec.HelmControllerSpec.ReleaseName,
-		Version:         controllerWatchResource.Spec.HelmControllerSpec.Version,
-		Values:          values,
-		CreateNamespace: createNamespace,
-	}, nil
+	if helmSpec.CreateNamespace != nil {
+		createNamespace = *helmSpec.CreateNamespace
+	}
+	opts := helm.InstallOptions{
+		ChartName:          helmSpec.Chart,
+		Namespace:          helmSpec.Namespace,
+		ReleaseName:        helmSpec.ReleaseName,
+		Version:            helmSpec.Version,
+		Values:             values,
+		CreateNamespace:    createNamespace,
+		ServiceAccountName: spec.ServiceAccountName,
+	}
+	opts.Install = actionOptions(helmSpec.Install)
+	opts.Upgrade = actionOptions(helmSpec.Upgrade)
+	if helmSpec.PostRenderer != nil {
+		opts.PostRenderer, err = postrenderer.New(helmSpec.Namespace, helmSpec.PostRenderer)
+		if err != nil {
+			return helm.InstallOptions{}, fmt.Errorf("invalid postRenderer: %w", err)
+		}
+	}
+	return opts, nil
+}
+
+// CRDInstallOptions returns the helm install options used to render the chart of a ControllerWatch spec for
+// extracting CRDs, taking crdSource and crdValues into account
+func CRDInstallOptions(spec *controllerv1alpha1.ControllerWatchSpec) (helm.InstallOptions, error) {
+	opts, err := HelmInstallOptions(spec)
+	if err != nil {
+		return helm.InstallOptions{}, err
+	}
+	if spec.CRDSource != nil {
+		if spec.CRDSource.Chart != "" {
+			opts.ChartName = spec.CRDSource.Chart
+			opts.Version = spec.CRDSource.Version
+		} else if spec.CRDSource.Version != "" {
+			opts.Version = spec.CRDSource.Version
+		}
+		if spec.CRDSource.Values != "" {
+			opts.Values, err = parseValues(spec.CRDSource.Values)
+			if err != nil {
+				return helm.InstallOptions{}, fmt.Errorf("invalid crdSource values: %w", err)
+			}
+		}
+	}
+	if spec.HelmControllerSpec.CRDValues != "" {
+		crdValues, err := parseValues(spec.HelmControllerSpec.CRDValues)
+		if err != nil {
+			return helm.InstallOptions{}, fmt.Errorf("invalid crdValues: %w", err)
+		}
+		opts.Values = chartutil.CoalesceTables(crdValues, opts.Values)
+	}
+	return opts, nil
+}
+
+// actionOptions converts a HelmActionSpec to helm options, waiting by default
+func actionOptions(spec *controllerv1alpha1.HelmActionSpec) helm.ActionOptions {
+	opts := helm.ActionOptions{Wait: true}
+	if spec == nil {
+		return opts
+	}
+	if spec.Timeout != nil {
+		opts.Timeout = spec.Timeout.Duration
+	}
+	if spec.Wait != nil {
+		opts.Wait = *spec.Wait
+	}
+	opts.WaitForJobs = spec.WaitForJobs
+	opts.Atomic = spec.Atomic
+	opts.DisableHooks = spec.DisableHooks
+	opts.SkipCRDs = spec.SkipCRDs
+	opts.MaxHistory = int(spec.MaxHistory)
+	return opts
+}
+
+func parseValues(values string) (map[string]interface{}, error) {
+	parsed := map[string]interface{}{}
+	if values == "" {
+		return parsed, nil
+	}
+	if err := yaml.Unmarshal([]byte(values), &parsed); err != nil {
+		return nil, err
+	}
+	return parsed, nil
 }
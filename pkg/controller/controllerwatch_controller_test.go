@@ -18,16 +18,19 @@ package controller
 
 import (
 	"context"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/helm"
 )
 
 var _ = Describe("ControllerWatch Controller", func() {
@@ -80,3 +83,55 @@ var _ = Describe("ControllerWatch Controller", func() {
 		})
 	})
 })
+
+var _ = Describe("actionOptions", func() {
+	It("waits with the default timeout when nothing is set", func() {
+		Expect(actionOptions(nil)).To(Equal(helm.ActionOptions{Wait: true}))
+		Expect(actionOptions(&controllerv1alpha1.HelmActionSpec{})).To(Equal(helm.ActionOptions{Wait: true}))
+	})
+
+	It("maps every field of the spec", func() {
+		spec := &controllerv1alpha1.HelmActionSpec{
+			Timeout:      &metav1.Duration{Duration: 2 * time.Minute},
+			Wait:         ptr.To(false),
+			WaitForJobs:  true,
+			Atomic:       true,
+			DisableHooks: true,
+			SkipCRDs:     true,
+			MaxHistory:   5,
+		}
+		Expect(actionOptions(spec)).To(Equal(helm.ActionOptions{
+			Timeout:      2 * time.Minute,
+			Wait:         false,
+			WaitForJobs:  true,
+			Atomic:       true,
+			DisableHooks: true,
+			SkipCRDs:     true,
+			MaxHistory:   5,
+		}))
+	})
+})
+
+var _ = Describe("idle", func() {
+	now := time.Now()
+	installed := func(finished time.Time) *controllerv1alpha1.ControllerWatch {
+		return &controllerv1alpha1.ControllerWatch{Status: controllerv1alpha1.ControllerWatchStatus{
+			ControllerInstallationStatus: controllerv1alpha1.ControllerInstallationStatusInstalled,
+			InstallProgress: &controllerv1alpha1.InstallProgress{
+				QueuedAt:   metav1.NewTime(finished.Add(-time.Minute)),
+				FinishedAt: &metav1.Time{Time: finished},
+			},
+		}}
+	}
+
+	It("protects a controller which was just installed", func() {
+		Expect(idle(installed(now.Add(-time.Minute)), now)).To(BeFalse())
+		Expect(idle(installed(now.Add(-time.Hour)), now)).To(BeTrue())
+	})
+
+	It("keeps a controller with custom resources", func() {
+		controllerWatch := installed(now.Add(-time.Hour))
+		controllerWatch.Status.Usage = []controllerv1alpha1.KindUsage{{Group: "example.com", Kind: "Example", Count: 1}}
+		Expect(idle(controllerWatch, now)).To(BeFalse())
+	})
+})
@@ -0,0 +1,60 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/helm"
+)
+
+// crdUpgradeCondition returns the CRDUpgradeSafe condition for the findings of the CRD upgrade checks.
+// A nil list of findings means the checks never ran, so there is no condition and the existing one is left alone
+func crdUpgradeCondition(controllerWatchResource controllerv1alpha1.ControllerWatchObject, findings []helm.CRDUpgradeFinding) *metav1.Condition {
+	if findings == nil {
+		return nil
+	}
+	condition := metav1.Condition{
+		Type:               controllerv1alpha1.ConditionCRDUpgradeSafe,
+		Status:             metav1.ConditionTrue,
+		Reason:             controllerv1alpha1.ReasonCRDUpgradeSafe,
+		Message:            "No incompatible CRD changes found",
+		ObservedGeneration: controllerWatchResource.GetGeneration(),
+	}
+	if len(findings) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = controllerv1alpha1.ReasonIncompatibleSchemaChanges
+		condition.Message = joinFindings(findings)
+		for _, finding := range findings {
+			if finding.Blocking {
+				condition.Reason = controllerv1alpha1.ReasonStoredVersionRemoved
+			}
+		}
+	}
+	return &condition
+}
+
+func joinFindings(findings []helm.CRDUpgradeFinding) string {
+	messages := []string{}
+	for _, finding := range findings {
+		messages = append(messages, finding.String())
+	}
+	return strings.Join(messages, "; ")
+}
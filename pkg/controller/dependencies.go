@@ -0,0 +1,144 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/statusupdate"
+)
+
+// reconcileDependencies hoists the dependencies of a ControllerWatch and returns true once all of them are installed.
+// The DependenciesReady condition is updated to reflect why the ControllerWatch is blocked
+func (r *ControllerWatchReconciler) reconcileDependencies(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, log logr.Logger) (bool, error) {
+	dependsOn := controllerWatchResource.GetSpec().DependsOn
+	if len(dependsOn) == 0 {
+		return true, nil
+	}
+
+	cycle, err := r.findDependencyCycle(ctx, controllerWatchResource, []string{controllerWatchResource.GetName()})
+	if err != nil {
+		return false, err
+	}
+	if cycle != nil {
+		message := "Dependency cycle detected: " + strings.Join(cycle, " -> ")
+		log.Info(message)
+		return false, r.setDependenciesCondition(ctx, controllerWatchResource, metav1.ConditionFalse, controllerv1alpha1.ReasonDependencyCycle, message)
+	}
+
+	waiting := []string{}
+	failed := []string{}
+	for _, name := range dependsOn {
+		dependency := r.newControllerWatch()
+		err := r.Get(ctx, client.ObjectKey{Namespace: controllerWatchResource.GetNamespace(), Name: name}, dependency)
+		if apierrors.IsNotFound(err) {
+			return false, r.setDependenciesCondition(ctx, controllerWatchResource, metav1.ConditionFalse, controllerv1alpha1.ReasonDependencyNotFound, fmt.Sprintf("Dependency %s not found", name))
+		}
+		if err != nil {
+			return false, err
+		}
+		switch dependency.GetStatus().ControllerInstallationStatus {
+		case controllerv1alpha1.ControllerInstallationStatusInstalled:
+			continue
+		case controllerv1alpha1.ControllerInstallationStatusPending, controllerv1alpha1.ControllerInstallationStatusInstalling:
+		case controllerv1alpha1.ControllerInstallationStatusInstallFailed:
+			// Hoisting it again would retry the failed install on every reconcile. It is retried once it is fixed or
+			// hoisted manually, which reconciles this ControllerWatch again
+			failed = append(failed, name)
+			continue
+		default:
+			// Hoist the dependency. Its own dependencies are hoisted when it is reconciled
+			log.Info("Hoisting dependency", "dependency", name)
+			r.Recorder.Eventf(controllerWatchResource, corev1.EventTypeNormal, "HoistingDependency", "Hoisting dependency %s", name)
+			if err := r.updateControllerInstallationStatus(ctx, dependency, controllerv1alpha1.ControllerInstallationStatusPending); err != nil {
+				return false, err
+			}
+		}
+		waiting = append(waiting, name)
+	}
+	if len(failed) > 0 {
+		message := "Dependencies failed to install: " + strings.Join(failed, ", ")
+		return false, r.setDependenciesCondition(ctx, controllerWatchResource, metav1.ConditionFalse, controllerv1alpha1.ReasonDependencyFailed, message)
+	}
+	if len(waiting) > 0 {
+		message := "Waiting for dependencies to be installed: " + strings.Join(waiting, ", ")
+		return false, r.setDependenciesCondition(ctx, controllerWatchResource, metav1.ConditionFalse, controllerv1alpha1.ReasonWaitingForDependencies, message)
+	}
+	return true, r.setDependenciesCondition(ctx, controllerWatchResource, metav1.ConditionTrue, controllerv1alpha1.ReasonDependenciesReady, "All dependencies are installed")
+}
+
+// findDependencyCycle walks the dependencies of a ControllerWatch depth first, and returns the path of the first cycle found
+func (r *ControllerWatchReconciler) findDependencyCycle(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, path []string) ([]string, error) {
+	for _, name := range controllerWatchResource.GetSpec().DependsOn {
+		if slices.Contains(path, name) {
+			return append(path, name), nil
+		}
+		dependency := r.newControllerWatch()
+		err := r.Get(ctx, client.ObjectKey{Namespace: controllerWatchResource.GetNamespace(), Name: name}, dependency)
+		if apierrors.IsNotFound(err) {
+			// Missing dependencies are reported separately
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		cycle, err := r.findDependencyCycle(ctx, dependency, append(slices.Clone(path), name))
+		if cycle != nil || err != nil {
+			return cycle, err
+		}
+	}
+	return nil, nil
+}
+
+func (r *ControllerWatchReconciler) setDependenciesCondition(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, status metav1.ConditionStatus, reason, message string) error {
+	return r.updateStatus(ctx, controllerWatchResource, statusupdate.SetCondition(metav1.Condition{
+		Type:               controllerv1alpha1.ConditionDependenciesReady,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: controllerWatchResource.GetGeneration(),
+	}))
+}
+
+// dependentsOf maps a ControllerWatch to the ControllerWatches which depend on it, so that they are reconciled
+// again when their dependencies change
+func (r *ControllerWatchReconciler) dependentsOf(ctx context.Context, obj client.Object) []reconcile.Request {
+	controllerWatches, err := r.listControllerWatches(ctx, obj.GetNamespace())
+	if err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list dependents of ControllerWatch", "ControllerWatch", obj.GetName())
+		return nil
+	}
+	requests := []reconcile.Request{}
+	for _, controllerWatch := range controllerWatches {
+		if slices.Contains(controllerWatch.GetSpec().DependsOn, obj.GetName()) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(controllerWatch)})
+		}
+	}
+	return requests
+}
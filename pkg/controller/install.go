@@ -0,0 +1,161 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/installer"
+	"github.com/cheeseandcereal/kubehoist/pkg/installpool"
+	"github.com/cheeseandcereal/kubehoist/pkg/statusupdate"
+)
+
+// reconcileInstall drives the installation of the controller of a Pending or Installing ControllerWatch.
+// The helm install runs in the install pool, and this only starts it and polls for its result. The ControllerWatch is
+// reconciled again whenever the install starts running or finishes
+func (r *ControllerWatchReconciler) reconcileInstall(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, log logr.Logger) error {
+	key := r.installKey(controllerWatchResource)
+	status := controllerWatchResource.GetStatus()
+	job, ok := r.InstallPool.Status(key)
+	if ok && job.Generation != controllerWatchResource.GetGeneration() {
+		// The spec changed since the install was started, so it is installing something outdated. An install which is
+		// already running is not interrupted, and the new install waits for it
+		log.Info("Replacing outdated install", "generation", job.Generation)
+		r.Recorder.Eventf(controllerWatchResource, corev1.EventTypeNormal, "InstallSuperseded", "Replaced install of generation %d because the spec changed", job.Generation)
+		r.InstallPool.Remove(key)
+		ok = false
+	}
+	if !ok {
+		if status.ControllerInstallationStatus == controllerv1alpha1.ControllerInstallationStatusInstalling {
+			// The install was cancelled or lost (i.e. kubehoist restarted), so start over
+			return r.updateControllerInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.ControllerInstallationStatusPending)
+		}
+		// Dependencies need to be installed first. This will be reconciled again when any of them change
+		ready, err := r.reconcileDependencies(ctx, controllerWatchResource, log)
+		if !ready || err != nil {
+			return err
+		}
+		requests, admitted, err := r.reconcileBudget(ctx, controllerWatchResource, log)
+		if !admitted || err != nil {
+			return err
+		}
+		return r.startInstall(ctx, controllerWatchResource, requests, log)
+	}
+
+	progress := statusupdate.SetInstallProgress(installProgress(job))
+	switch job.State {
+	case installpool.StateSucceeded:
+		r.InstallPool.Remove(key)
+		log.Info("Successfully installed helm chart")
+		return r.updateControllerInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.ControllerInstallationStatusInstalled, progress)
+	case installpool.StateFailed:
+		r.InstallPool.Remove(key)
+		log.Error(job.Err, "Failed to install helm chart")
+		r.Recorder.Eventf(controllerWatchResource, corev1.EventTypeWarning, "InstallFailed", "Failed to install helm chart: %v", job.Err)
+		return r.updateControllerInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.ControllerInstallationStatusInstallFailed, progress)
+	default:
+		// Still queued or running
+		return r.updateControllerInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.ControllerInstallationStatusInstalling, progress)
+	}
+}
+
+func (r *ControllerWatchReconciler) startInstall(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, requests corev1.ResourceList, log logr.Logger) error {
+	log.Info("Installing Chart", "chart", controllerWatchResource.GetSpec().HelmControllerSpec.Chart)
+	helmInstallOpts, err := r.getHelmInstallOptions(controllerWatchResource, log)
+	if err != nil {
+		r.Recorder.Eventf(controllerWatchResource, corev1.EventTypeWarning, "InstallFailed", "Invalid helm install options: %v", err)
+		return r.updateControllerInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.ControllerInstallationStatusInstallFailed)
+	}
+	key := r.installKey(controllerWatchResource)
+	// The install supersedes any uninstall which is still queued or was not collected, and waits for one which is running
+	r.InstallPool.Remove(r.sleepKey(controllerWatchResource))
+	work := func(ctx context.Context) (string, error) {
+		return "", r.HelmClient.InstallChart(ctx, helmInstallOpts)
+	}
+	if r.InstallerJobs != nil {
+		request := &installer.Request{Spec: *controllerWatchResource.GetSpec().DeepCopy()}
+		request.Spec.HelmControllerSpec.CreateNamespace = &helmInstallOpts.CreateNamespace
+		if r.Namespaced {
+			request.Namespace = controllerWatchResource.GetNamespace()
+			request.AllowedCRDGroups = r.AllowedNamespacedCRDGroups
+		}
+		work = func(ctx context.Context) (string, error) {
+			return r.InstallerJobs.Run(ctx, client.ObjectKeyFromObject(controllerWatchResource), request)
+		}
+	}
+	r.InstallPool.Submit(key, key, controllerWatchResource.GetGeneration(), work, r.notifyInstallEvent(controllerWatchResource))
+	job, _ := r.InstallPool.Status(key)
+	return r.updateControllerInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.ControllerInstallationStatusInstalling,
+		statusupdate.SetInstallProgress(installProgress(job)), statusupdate.SetResourceRequests(requests))
+}
+
+// notifyInstallEvent returns a function which enqueues a reconcile of the ControllerWatch when its job in the install
+// pool starts or finishes
+func (r *ControllerWatchReconciler) notifyInstallEvent(controllerWatchResource controllerv1alpha1.ControllerWatchObject) func() {
+	// Only the name and namespace are needed to enqueue a reconcile
+	notifyObj := r.newControllerWatch()
+	notifyObj.SetName(controllerWatchResource.GetName())
+	notifyObj.SetNamespace(controllerWatchResource.GetNamespace())
+	return func() {
+		r.installEvents <- event.GenericEvent{Object: notifyObj}
+	}
+}
+
+// cancelInstall forgets the install of the controller, cancelling it if it is still queued. An install which is
+// already running finishes before anything else runs for the controller
+func (r *ControllerWatchReconciler) cancelInstall(controllerWatchResource controllerv1alpha1.ControllerWatchObject) {
+	r.InstallPool.Remove(r.installKey(controllerWatchResource))
+}
+
+// installKey identifies the install of a ControllerWatch in the install pool, which is shared by both kinds
+func (r *ControllerWatchReconciler) installKey(controllerWatchResource controllerv1alpha1.ControllerWatchObject) string {
+	if r.Namespaced {
+		return fmt.Sprintf("namespacedcontrollerwatch/%s/%s", controllerWatchResource.GetNamespace(), controllerWatchResource.GetName())
+	}
+	return "controllerwatch/" + controllerWatchResource.GetName()
+}
+
+func installProgress(job installpool.Status) *controllerv1alpha1.InstallProgress {
+	progress := &controllerv1alpha1.InstallProgress{
+		Generation: job.Generation,
+		State:      string(job.State),
+		QueuedAt:   metav1.Time{Time: job.QueuedAt},
+		StartedAt:  optionalTime(job.StartedAt),
+		FinishedAt: optionalTime(job.FinishedAt),
+	}
+	if job.Err != nil {
+		progress.Message = job.Err.Error()
+	}
+	progress.Logs = job.Output
+	return progress
+}
+
+func optionalTime(t time.Time) *metav1.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &metav1.Time{Time: t}
+}
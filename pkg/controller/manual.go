@@ -0,0 +1,141 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/installpool"
+)
+
+// handleManualRequests acts on the hoist and sleep annotations of a ControllerWatch, and clears them once handled.
+// It returns true if the reconciliation should not continue
+func (r *ControllerWatchReconciler) handleManualRequests(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, log logr.Logger) (bool, error) {
+	annotations := controllerWatchResource.GetAnnotations()
+	if value, ok := annotations[controllerv1alpha1.SleepAnnotation]; ok {
+		fieldManager := annotationFieldManager(controllerWatchResource, controllerv1alpha1.SleepAnnotation)
+		if value != controllerv1alpha1.ManualRequestValue {
+			r.Recorder.Eventf(controllerWatchResource, corev1.EventTypeWarning, "InvalidSleepRequest", "Ignoring %s=%s set by field manager %s", controllerv1alpha1.SleepAnnotation, value, fieldManager)
+			return true, r.clearAnnotation(ctx, controllerWatchResource, controllerv1alpha1.SleepAnnotation)
+		}
+		if !r.sleepInProgress(controllerWatchResource) {
+			log.Info("Manual sleep requested", "fieldManager", fieldManager)
+			r.Recorder.Eventf(controllerWatchResource, corev1.EventTypeNormal, "SleepRequested", "Manual sleep requested by field manager %s", fieldManager)
+		}
+		// The annotation is kept until the uninstall finished, so that nothing is installed in the meantime
+		if slept, err := r.sleepController(ctx, controllerWatchResource, log); !slept || err != nil {
+			return true, err
+		}
+		return true, r.clearAnnotation(ctx, controllerWatchResource, controllerv1alpha1.SleepAnnotation)
+	}
+	if value, ok := annotations[controllerv1alpha1.HoistAnnotation]; ok {
+		fieldManager := annotationFieldManager(controllerWatchResource, controllerv1alpha1.HoistAnnotation)
+		if value != controllerv1alpha1.ManualRequestValue {
+			r.Recorder.Eventf(controllerWatchResource, corev1.EventTypeWarning, "InvalidHoistRequest", "Ignoring %s=%s set by field manager %s", controllerv1alpha1.HoistAnnotation, value, fieldManager)
+			return true, r.clearAnnotation(ctx, controllerWatchResource, controllerv1alpha1.HoistAnnotation)
+		}
+		log.Info("Manual hoist requested", "fieldManager", fieldManager)
+		r.Recorder.Eventf(controllerWatchResource, corev1.EventTypeNormal, "HoistRequested", "Manual hoist requested by field manager %s", fieldManager)
+		if err := r.clearAnnotation(ctx, controllerWatchResource, controllerv1alpha1.HoistAnnotation); err != nil {
+			return true, err
+		}
+		// Setting the status to pending will (re)install the controller for the rest of this reconciliation
+		if err := r.updateControllerInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.ControllerInstallationStatusPending); err != nil {
+			return true, err
+		}
+	}
+	return false, nil
+}
+
+// sleepController uninstalls the controller in the install pool, dropping any queued install and waiting for one which
+// is already running. It returns true once the uninstall finished and the controller is sleeping. The ControllerWatch is reconciled again when the
+// uninstall starts running or finishes
+func (r *ControllerWatchReconciler) sleepController(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, log logr.Logger) (bool, error) {
+	key := r.sleepKey(controllerWatchResource)
+	job, ok := r.InstallPool.Status(key)
+	if !ok {
+		log.Info("Uninstalling Chart", "chart", controllerWatchResource.GetSpec().HelmControllerSpec.Chart)
+		r.cancelInstall(controllerWatchResource)
+		helmInstallOpts, err := r.getHelmInstallOptions(controllerWatchResource, log)
+		if err != nil {
+			return false, err
+		}
+		work := func(ctx context.Context) (string, error) {
+			return "", r.HelmClient.UninstallChart(helmInstallOpts)
+		}
+		r.InstallPool.Submit(key, r.installKey(controllerWatchResource), controllerWatchResource.GetGeneration(), work, r.notifyInstallEvent(controllerWatchResource))
+		return false, nil
+	}
+	switch job.State {
+	case installpool.StateSucceeded:
+		r.InstallPool.Remove(key)
+		log.Info("Successfully uninstalled helm chart")
+		return true, r.updateControllerInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.ControllerInstallationStatusSleeping)
+	case installpool.StateFailed:
+		r.InstallPool.Remove(key)
+		log.Error(job.Err, "Failed to uninstall helm chart")
+		r.Recorder.Eventf(controllerWatchResource, corev1.EventTypeWarning, "SleepFailed", "Failed to uninstall helm chart: %v", job.Err)
+		return false, job.Err
+	default:
+		// Still queued or running
+		return false, nil
+	}
+}
+
+// sleepInProgress returns true if the controller is being uninstalled in the install pool
+func (r *ControllerWatchReconciler) sleepInProgress(controllerWatchResource controllerv1alpha1.ControllerWatchObject) bool {
+	_, ok := r.InstallPool.Status(r.sleepKey(controllerWatchResource))
+	return ok
+}
+
+// sleepKey identifies the uninstall of a ControllerWatch in the install pool
+func (r *ControllerWatchReconciler) sleepKey(controllerWatchResource controllerv1alpha1.ControllerWatchObject) string {
+	return r.installKey(controllerWatchResource) + "/sleep"
+}
+
+func (r *ControllerWatchReconciler) clearAnnotation(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, annotation string) error {
+	patch := client.MergeFrom(controllerWatchResource.DeepCopyObject().(client.Object))
+	annotations := controllerWatchResource.GetAnnotations()
+	delete(annotations, annotation)
+	controllerWatchResource.SetAnnotations(annotations)
+	return r.Patch(ctx, controllerWatchResource, patch)
+}
+
+// annotationFieldManager returns the name of the field manager which set the given annotation
+func annotationFieldManager(obj client.Object, annotation string) string {
+	for _, entry := range obj.GetManagedFields() {
+		if entry.FieldsV1 == nil {
+			continue
+		}
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		metadata, _ := fields["f:metadata"].(map[string]interface{})
+		annotations, _ := metadata["f:annotations"].(map[string]interface{})
+		if _, ok := annotations["f:"+annotation]; ok {
+			return entry.Manager
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/installpool"
+	"github.com/cheeseandcereal/kubehoist/pkg/migrator"
+	"github.com/cheeseandcereal/kubehoist/pkg/statusupdate"
+)
+
+// reconcileStorageVersionMigrations migrates the stored objects of the installed CRDs to their current storage version
+// when spec.migrateStorageVersions is set. Progress is reported in status.storageVersionMigrations.
+// A migration rewrites objects in every namespace, so it is only supported for cluster scoped ControllerWatches
+func (r *ControllerWatchReconciler) reconcileStorageVersionMigrations(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, log logr.Logger) error {
+	if !controllerWatchResource.GetSpec().MigrateStorageVersions || r.Namespaced {
+		return nil
+	}
+	crds, err := r.installedCRDDefinitions(ctx, controllerWatchResource)
+	if err != nil {
+		return err
+	}
+	status := controllerWatchResource.GetStatus()
+	for _, crd := range crds {
+		key := r.migrationKey(controllerWatchResource, crd.Name)
+		if job, ok := r.InstallPool.Status(key); ok {
+			if !job.Finished() {
+				continue
+			}
+			// The migration already recorded its result in the status
+			r.InstallPool.Remove(key)
+			if job.State == installpool.StateFailed {
+				log.Error(job.Err, "Failed to migrate stored objects", "crd", crd.Name)
+				r.Recorder.Eventf(controllerWatchResource, corev1.EventTypeWarning, "StorageVersionMigrationFailed", "Failed to migrate %s: %v", crd.Name, job.Err)
+				// Returning the error retries the migration with a backoff
+				return job.Err
+			}
+			log.Info("Migrated stored objects to the storage version", "crd", crd.Name)
+			r.Recorder.Event(controllerWatchResource, corev1.EventTypeNormal, "StorageVersionMigrationCompleted", job.Output)
+			continue
+		}
+		if !migrator.NeedsMigration(crd) {
+			continue
+		}
+		migration := controllerv1alpha1.StorageVersionMigrationStatus{CRD: crd.Name, StorageVersion: migrator.StorageVersion(crd)}
+		usesWebhook := crd.Spec.Conversion != nil && crd.Spec.Conversion.Strategy == apiextensionsv1.WebhookConverter
+		if usesWebhook && status.ControllerInstallationStatus != controllerv1alpha1.ControllerInstallationStatusInstalled {
+			// The conversion webhook is most likely served by the controller itself, so wait until it is installed
+			migration.State = controllerv1alpha1.StorageVersionMigrationWaitingForController
+			if err := r.updateStatus(ctx, controllerWatchResource, statusupdate.SetStorageVersionMigration(migration)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		log.Info("Migrating stored objects to the storage version", "crd", crd.Name, "storageVersion", migration.StorageVersion)
+		r.Recorder.Eventf(controllerWatchResource, corev1.EventTypeNormal, "StorageVersionMigrationStarted", "Migrating %s to %s", crd.Name, migration.StorageVersion)
+		migration.State = controllerv1alpha1.StorageVersionMigrationRunning
+		if err := r.updateStatus(ctx, controllerWatchResource, statusupdate.SetStorageVersionMigration(migration)); err != nil {
+			return err
+		}
+		r.InstallPool.Submit(key, "", controllerWatchResource.GetGeneration(), r.migrate(client.ObjectKeyFromObject(controllerWatchResource), crd, migration),
+			r.notifyInstallEvent(controllerWatchResource))
+	}
+	return nil
+}
+
+// migrate returns the work which migrates a CRD in the install pool. It runs outside of the reconciliation, so it
+// records its progress in a copy of the ControllerWatch of its own
+func (r *ControllerWatchReconciler) migrate(key client.ObjectKey, crd *apiextensionsv1.CustomResourceDefinition, migration controllerv1alpha1.StorageVersionMigrationStatus) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		controllerWatchResource := r.newControllerWatch()
+		if err := r.Get(ctx, key, controllerWatchResource); err != nil {
+			return "", err
+		}
+		m := &migrator.Migrator{Client: r.Client}
+		migrated, err := m.Migrate(ctx, crd, func(migrated int) error {
+			migration.Migrated = int32(migrated)
+			return r.updateStatus(ctx, controllerWatchResource, statusupdate.SetStorageVersionMigration(migration))
+		})
+		migration.Migrated = int32(migrated)
+		migration.State = controllerv1alpha1.StorageVersionMigrationCompleted
+		if err != nil {
+			migration.State = controllerv1alpha1.StorageVersionMigrationFailed
+			migration.Message = err.Error()
+		}
+		if updateErr := r.updateStatus(ctx, controllerWatchResource, statusupdate.SetStorageVersionMigration(migration)); updateErr != nil && err == nil {
+			err = updateErr
+		}
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Migrated %d objects of %s to %s", migrated, crd.Name, migration.StorageVersion), nil
+	}
+}
+
+// migrationKey identifies the migration of a CRD of a ControllerWatch in the install pool
+func (r *ControllerWatchReconciler) migrationKey(controllerWatchResource controllerv1alpha1.ControllerWatchObject, crd string) string {
+	return r.installKey(controllerWatchResource) + "/migrate/" + crd
+}
+
+// installedCRDDefinitions returns the CRDs for all of the kinds in status.installedCRDs
+func (r *ControllerWatchReconciler) installedCRDDefinitions(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject) ([]*apiextensionsv1.CustomResourceDefinition, error) {
+	crdList := &apiextensionsv1.CustomResourceDefinitionList{}
+	if err := r.List(ctx, crdList); err != nil {
+		return nil, err
+	}
+	crds := []*apiextensionsv1.CustomResourceDefinition{}
+	for i := range crdList.Items {
+		crd := &crdList.Items[i]
+		for _, installed := range controllerWatchResource.GetStatus().InstalledCRDs {
+			if installed.Group == crd.Spec.Group && installed.Kind == crd.Spec.Names.Kind {
+				crds = append(crds, crd)
+				break
+			}
+		}
+	}
+	return crds, nil
+}
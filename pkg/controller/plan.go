@@ -0,0 +1,122 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/helm"
+	"github.com/cheeseandcereal/kubehoist/pkg/statusupdate"
+)
+
+// reconcilePlan computes status.plan for a ControllerWatch with spec.dryRun set. The plan is only computed again when
+// the spec changes
+func (r *ControllerWatchReconciler) reconcilePlan(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, log logr.Logger) error {
+	status := controllerWatchResource.GetStatus()
+	if status.Plan != nil && status.Plan.ObservedGeneration == controllerWatchResource.GetGeneration() {
+		return nil
+	}
+	log.Info("Computing plan", "chart", controllerWatchResource.GetSpec().HelmControllerSpec.Chart)
+	plan := &controllerv1alpha1.Plan{
+		ObservedGeneration: controllerWatchResource.GetGeneration(),
+		ComputedAt:         metav1.Time{Time: time.Now()},
+	}
+	if err := r.computePlan(ctx, controllerWatchResource, plan, log); err != nil {
+		log.Error(err, "Failed to compute plan")
+		r.Recorder.Eventf(controllerWatchResource, corev1.EventTypeWarning, "PlanFailed", "Failed to compute plan: %v", err)
+		plan.Error = err.Error()
+	} else {
+		r.Recorder.Eventf(controllerWatchResource, corev1.EventTypeNormal, "Planned", "Planned %d CRDs and %d resources", len(plan.CRDs), len(plan.Resources))
+	}
+	return r.updateStatus(ctx, controllerWatchResource, statusupdate.SetPlan(plan))
+}
+
+func (r *ControllerWatchReconciler) computePlan(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, plan *controllerv1alpha1.Plan, log logr.Logger) error {
+	crdOpts, err := r.getCRDInstallOptions(controllerWatchResource, log)
+	if err != nil {
+		return err
+	}
+	crds, _, err := r.HelmClient.RenderChartCRDs(ctx, crdOpts)
+	if err != nil {
+		return err
+	}
+	if len(crds) == 0 {
+		return fmt.Errorf("no CRDs found in helm chart")
+	}
+	for _, crd := range crds {
+		if crdOpts.CRDAllowed != nil && !crdOpts.CRDAllowed(crd) {
+			return fmt.Errorf("%w: %s", helm.ErrCRDNotAllowed, crd.Name)
+		}
+	}
+	crdPlans, err := helm.PlanCRDs(ctx, r.Client, crds, crdOpts)
+	if err != nil {
+		return err
+	}
+	for _, crdPlan := range crdPlans {
+		planned := controllerv1alpha1.PlannedCRD{Name: crdPlan.Name, Action: string(crdPlan.Action)}
+		for _, finding := range crdPlan.Findings {
+			planned.Findings = append(planned.Findings, finding.Message)
+		}
+		plan.CRDs = append(plan.CRDs, planned)
+	}
+
+	helmOpts, err := r.getHelmInstallOptions(controllerWatchResource, log)
+	if err != nil {
+		return err
+	}
+	manifest, err := r.HelmClient.RenderChart(ctx, helmOpts)
+	if err != nil {
+		return err
+	}
+	resources, err := helm.ExtractResources(manifest)
+	if err != nil {
+		return err
+	}
+	nonCRDs := []*unstructured.Unstructured{}
+	for _, resource := range resources {
+		gvk := resource.GroupVersionKind()
+		if gvk.Group == apiextensionsv1.GroupName && gvk.Kind == "CustomResourceDefinition" {
+			// CRDs are planned separately above
+			continue
+		}
+		nonCRDs = append(nonCRDs, resource)
+	}
+	for _, resourcePlan := range helm.PlanResources(ctx, r.Client, nonCRDs, helmOpts.Namespace) {
+		resource := resourcePlan.Resource
+		planned := controllerv1alpha1.PlannedResource{
+			APIVersion: resource.GetAPIVersion(),
+			Kind:       resource.GetKind(),
+			Namespace:  resource.GetNamespace(),
+			Name:       resource.GetName(),
+			Action:     string(resourcePlan.Action),
+		}
+		if resourcePlan.Err != nil {
+			planned.Message = resourcePlan.Err.Error()
+		}
+		plan.Resources = append(plan.Resources, planned)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/helm"
+)
+
+// recoverOnStartup runs once this manager becomes the leader. It rebuilds the watchers of every ControllerWatch from
+// its status, and cleans up installs which a previous leader left behind when it died, so that they are started over.
+// Reconciling waits until this is done
+func (r *ControllerWatchReconciler) recoverOnStartup(ctx context.Context) error {
+	defer close(r.recovered)
+	log := log.FromContext(ctx).WithName("recovery")
+	if !r.Manager.GetCache().WaitForCacheSync(ctx) {
+		return errors.New("failed to wait for caches to sync")
+	}
+	controllerWatches, err := r.listControllerWatches(ctx, "")
+	if err != nil {
+		return err
+	}
+	for _, controllerWatchResource := range controllerWatches {
+		log := log.WithValues("ControllerWatch", client.ObjectKeyFromObject(controllerWatchResource))
+		status := controllerWatchResource.GetStatus()
+		if status.CRDsInstallationStatus == controllerv1alpha1.CRDInstallationStatusInstalled {
+			if err := r.ensureWatchers(controllerWatchResource, log); err != nil {
+				// Keep going, the watchers are set up again when the ControllerWatch is reconciled
+				log.Error(err, "Failed to rebuild watchers")
+			}
+		}
+		if status.ControllerInstallationStatus == controllerv1alpha1.ControllerInstallationStatusInstalling {
+			if err := r.recoverInstall(ctx, controllerWatchResource, log); err != nil {
+				// Keep going, the install is retried from Pending anyways
+				log.Error(err, "Failed to recover interrupted install")
+			}
+		}
+	}
+	log.Info("Recovered state of ControllerWatches", "count", len(controllerWatches))
+	// Block like every other runnable until the manager stops
+	<-ctx.Done()
+	return nil
+}
+
+// recoverInstall cleans up an install which was interrupted because the previous leader died, and resets the
+// ControllerWatch to Pending so that it is installed again
+func (r *ControllerWatchReconciler) recoverInstall(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, log logr.Logger) error {
+	if r.InstallerJobs != nil {
+		// The Job of the previous leader may still be running and would fight with the recovery and the new install, so
+		// this waits until its pod stopped
+		if err := r.InstallerJobs.DeleteJobs(ctx, client.ObjectKeyFromObject(controllerWatchResource)); err != nil {
+			return err
+		}
+	}
+	helmInstallOpts, err := r.getHelmInstallOptions(controllerWatchResource, log)
+	if err == nil {
+		recovery, err := r.HelmClient.RecoverPendingRelease(helmInstallOpts)
+		if err != nil {
+			return err
+		}
+		if recovery != helm.ReleaseRecoveryNone {
+			log.Info("Recovered release left pending by an interrupted install", "release", helmInstallOpts.ReleaseName, "recovery", recovery)
+			r.Recorder.Eventf(controllerWatchResource, corev1.EventTypeNormal, "ReleaseRecovered", "Release %s was left pending by an interrupted install: %s", helmInstallOpts.ReleaseName, recovery)
+		}
+	}
+	return r.updateControllerInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.ControllerInstallationStatusPending)
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/schedule"
+)
+
+// reconcileSchedule installs or uninstalls the controller according to the currently active schedule window.
+// It returns when the schedule needs to be evaluated again, and true if the reconciliation should not continue
+func (r *ControllerWatchReconciler) reconcileSchedule(ctx context.Context, controllerWatchResource controllerv1alpha1.ControllerWatchObject, log logr.Logger) (time.Time, bool, error) {
+	now := time.Now()
+	state, nextBoundary, err := schedule.Evaluate(controllerWatchResource.GetSpec().Schedule, now)
+	if err != nil {
+		log.Error(err, "Invalid schedule")
+		r.Recorder.Eventf(controllerWatchResource, corev1.EventTypeWarning, "InvalidSchedule", "Invalid schedule: %v", err)
+		// The schedule can't be fixed without changing the spec, so don't retry
+		return time.Time{}, false, nil
+	}
+
+	installationStatus := controllerWatchResource.GetStatus().ControllerInstallationStatus
+	switch state {
+	case schedule.StateHibernate:
+		if installationStatus.Hoisted() {
+			if !r.sleepInProgress(controllerWatchResource) {
+				log.Info("Hibernate window is active, putting controller to sleep")
+				r.Recorder.Event(controllerWatchResource, corev1.EventTypeNormal, "ScheduledSleep", "Hibernate window is active")
+			}
+			if _, err := r.sleepController(ctx, controllerWatchResource, log); err != nil {
+				return nextBoundary, true, err
+			}
+		}
+		// Nothing else should be installed while hibernating
+		return nextBoundary, true, nil
+	case schedule.StateWarm:
+		if !installationStatus.Hoisted() {
+			log.Info("Warm window is active, hoisting controller")
+			r.Recorder.Event(controllerWatchResource, corev1.EventTypeNormal, "ScheduledHoist", "Warm window is active")
+			if err := r.updateControllerInstallationStatus(ctx, controllerWatchResource, controllerv1alpha1.ControllerInstallationStatusPending); err != nil {
+				return nextBoundary, true, err
+			}
+		}
+	default:
+		// Put the controller back to sleep once a warm window ended, unless it was installed again since then, i.e.
+		// because its custom resources were used
+		warmEnd, err := schedule.LastWarmWindowEnd(controllerWatchResource.GetSpec().Schedule, now)
+		if err != nil || warmEnd.IsZero() || !installedBefore(controllerWatchResource, warmEnd) {
+			return nextBoundary, false, err
+		}
+		if !r.sleepInProgress(controllerWatchResource) {
+			log.Info("Warm window ended, putting controller to sleep")
+			r.Recorder.Event(controllerWatchResource, corev1.EventTypeNormal, "ScheduledSleep", "Warm window ended")
+		}
+		slept, err := r.sleepController(ctx, controllerWatchResource, log)
+		return nextBoundary, !slept || err != nil, err
+	}
+	return nextBoundary, false, nil
+}
+
+// installedBefore returns true if the controller is installed, and its last install was started before the given time
+func installedBefore(controllerWatchResource controllerv1alpha1.ControllerWatchObject, t time.Time) bool {
+	status := controllerWatchResource.GetStatus()
+	if status.ControllerInstallationStatus != controllerv1alpha1.ControllerInstallationStatusInstalled {
+		return false
+	}
+	return status.InstallProgress == nil || status.InstallProgress.QueuedAt.Time.Before(t)
+}
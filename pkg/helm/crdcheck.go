@@ -0,0 +1,122 @@
+package helm
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// CRDUpgradeFinding is a potential problem with applying a changed CRD over the CRD which is currently installed
+type CRDUpgradeFinding struct {
+	// CRD is the name of the CRD
+	CRD string
+	// Blocking findings prevent the CRDs from being applied at all
+	Blocking bool
+	Message  string
+}
+
+func (f CRDUpgradeFinding) String() string {
+	return f.CRD + ": " + f.Message
+}
+
+// CheckCRDUpgrade compares a CRD with the currently installed version of it.
+// Removing a version which still has stored objects is blocking, and incompatible schema changes which could make
+// existing custom resources invalid are reported as non-blocking findings
+func CheckCRDUpgrade(live, desired *apiextensionsv1.CustomResourceDefinition) []CRDUpgradeFinding {
+	findings := []CRDUpgradeFinding{}
+	desiredVersions := map[string]*apiextensionsv1.CustomResourceDefinitionVersion{}
+	for i := range desired.Spec.Versions {
+		desiredVersions[desired.Spec.Versions[i].Name] = &desired.Spec.Versions[i]
+	}
+	for _, storedVersion := range live.Status.StoredVersions {
+		if _, ok := desiredVersions[storedVersion]; !ok {
+			findings = append(findings, CRDUpgradeFinding{
+				CRD:      desired.Name,
+				Blocking: true,
+				Message:  fmt.Sprintf("version %s is removed, but is still listed in status.storedVersions", storedVersion),
+			})
+		}
+	}
+	for _, liveVersion := range live.Spec.Versions {
+		desiredVersion, ok := desiredVersions[liveVersion.Name]
+		if !ok {
+			continue
+		}
+		if liveVersion.Served && !desiredVersion.Served {
+			findings = append(findings, CRDUpgradeFinding{CRD: desired.Name, Message: fmt.Sprintf("version %s is no longer served", liveVersion.Name)})
+		}
+		if liveVersion.Schema == nil || desiredVersion.Schema == nil {
+			continue
+		}
+		messages := []string{}
+		compareSchemas(liveVersion.Name, liveVersion.Schema.OpenAPIV3Schema, desiredVersion.Schema.OpenAPIV3Schema, &messages)
+		for _, message := range messages {
+			findings = append(findings, CRDUpgradeFinding{CRD: desired.Name, Message: message})
+		}
+	}
+	return findings
+}
+
+// compareSchemas appends a message for every change between the live and desired schema which could make existing
+// objects invalid
+func compareSchemas(path string, live, desired *apiextensionsv1.JSONSchemaProps, messages *[]string) {
+	if live == nil || desired == nil {
+		return
+	}
+	if live.Type != "" && desired.Type != "" && live.Type != desired.Type {
+		*messages = append(*messages, fmt.Sprintf("type of %s changed from %s to %s", path, live.Type, desired.Type))
+		return
+	}
+	for _, required := range desired.Required {
+		if !slices.Contains(live.Required, required) {
+			*messages = append(*messages, fmt.Sprintf("%s.%s is now required", path, required))
+		}
+	}
+	if len(desired.Enum) > 0 && len(live.Enum) == 0 {
+		*messages = append(*messages, fmt.Sprintf("values of %s are now restricted to an enum", path))
+	} else if len(desired.Enum) > 0 {
+		for _, value := range live.Enum {
+			if !slices.ContainsFunc(desired.Enum, func(v apiextensionsv1.JSON) bool { return string(v.Raw) == string(value.Raw) }) {
+				*messages = append(*messages, fmt.Sprintf("value %s is no longer allowed for %s", value.Raw, path))
+			}
+		}
+	}
+	if desired.MaxLength != nil && (live.MaxLength == nil || *desired.MaxLength < *live.MaxLength) {
+		*messages = append(*messages, fmt.Sprintf("max length of %s was reduced", path))
+	}
+	if desired.MinLength != nil && (live.MinLength == nil || *desired.MinLength > *live.MinLength) {
+		*messages = append(*messages, fmt.Sprintf("min length of %s was increased", path))
+	}
+	if desired.MaxItems != nil && (live.MaxItems == nil || *desired.MaxItems < *live.MaxItems) {
+		*messages = append(*messages, fmt.Sprintf("max items of %s was reduced", path))
+	}
+	if desired.MinItems != nil && (live.MinItems == nil || *desired.MinItems > *live.MinItems) {
+		*messages = append(*messages, fmt.Sprintf("min items of %s was increased", path))
+	}
+	if desired.Maximum != nil && (live.Maximum == nil || *desired.Maximum < *live.Maximum) {
+		*messages = append(*messages, fmt.Sprintf("maximum of %s was reduced", path))
+	}
+	if desired.Minimum != nil && (live.Minimum == nil || *desired.Minimum > *live.Minimum) {
+		*messages = append(*messages, fmt.Sprintf("minimum of %s was increased", path))
+	}
+	if desired.Pattern != "" && desired.Pattern != live.Pattern {
+		*messages = append(*messages, fmt.Sprintf("pattern of %s changed", path))
+	}
+	preservesUnknownFields := desired.XPreserveUnknownFields != nil && *desired.XPreserveUnknownFields
+	for _, name := range slices.Sorted(maps.Keys(live.Properties)) {
+		liveProperty := live.Properties[name]
+		desiredProperty, ok := desired.Properties[name]
+		if !ok {
+			if !preservesUnknownFields {
+				*messages = append(*messages, fmt.Sprintf("%s.%s was removed", path, name))
+			}
+			continue
+		}
+		compareSchemas(path+"."+name, &liveProperty, &desiredProperty, messages)
+	}
+	if live.Items != nil && desired.Items != nil {
+		compareSchemas(path+"[*]", live.Items.Schema, desired.Items.Schema, messages)
+	}
+}
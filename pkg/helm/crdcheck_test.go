@@ -0,0 +1,147 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func testCRD(storedVersions []string, versions ...apiextensionsv1.CustomResourceDefinitionVersion) *apiextensionsv1.CustomResourceDefinition {
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "widgets.example.com"},
+		Spec:       apiextensionsv1.CustomResourceDefinitionSpec{Versions: versions},
+		Status:     apiextensionsv1.CustomResourceDefinitionStatus{StoredVersions: storedVersions},
+	}
+}
+
+func testVersion(name string, spec apiextensionsv1.JSONSchemaProps) apiextensionsv1.CustomResourceDefinitionVersion {
+	return apiextensionsv1.CustomResourceDefinitionVersion{
+		Name:   name,
+		Served: true,
+		Schema: &apiextensionsv1.CustomResourceValidation{
+			OpenAPIV3Schema: &apiextensionsv1.JSONSchemaProps{
+				Type:       "object",
+				Properties: map[string]apiextensionsv1.JSONSchemaProps{"spec": spec},
+			},
+		},
+	}
+}
+
+var widgetSpec = apiextensionsv1.JSONSchemaProps{
+	Type: "object",
+	Properties: map[string]apiextensionsv1.JSONSchemaProps{
+		"size":  {Type: "string", Enum: []apiextensionsv1.JSON{{Raw: []byte(`"small"`)}, {Raw: []byte(`"large"`)}}},
+		"count": {Type: "integer"},
+	},
+}
+
+var _ = Describe("CheckCRDUpgrade", func() {
+	It("finds nothing for an identical CRD", func() {
+		live := testCRD([]string{"v1"}, testVersion("v1", widgetSpec))
+		Expect(CheckCRDUpgrade(live, live.DeepCopy())).To(BeEmpty())
+	})
+
+	It("blocks removing a version which is still stored", func() {
+		live := testCRD([]string{"v1alpha1", "v1"}, testVersion("v1alpha1", widgetSpec), testVersion("v1", widgetSpec))
+		desired := testCRD(nil, testVersion("v1", widgetSpec))
+		Expect(CheckCRDUpgrade(live, desired)).To(Equal([]CRDUpgradeFinding{{
+			CRD:      "widgets.example.com",
+			Blocking: true,
+			Message:  "version v1alpha1 is removed, but is still listed in status.storedVersions",
+		}}))
+	})
+
+	It("allows removing a version which is not stored", func() {
+		live := testCRD([]string{"v1"}, testVersion("v1alpha1", widgetSpec), testVersion("v1", widgetSpec))
+		desired := testCRD(nil, testVersion("v1", widgetSpec))
+		Expect(CheckCRDUpgrade(live, desired)).To(BeEmpty())
+	})
+
+	It("warns on incompatible schema changes", func() {
+		live := testCRD([]string{"v1"}, testVersion("v1", widgetSpec))
+		desiredSpec := apiextensionsv1.JSONSchemaProps{
+			Type:     "object",
+			Required: []string{"size"},
+			Properties: map[string]apiextensionsv1.JSONSchemaProps{
+				"size": {Type: "string", Enum: []apiextensionsv1.JSON{{Raw: []byte(`"large"`)}}},
+			},
+		}
+		desired := testCRD(nil, testVersion("v1", desiredSpec))
+		findings := CheckCRDUpgrade(live, desired)
+		messages := []string{}
+		for _, finding := range findings {
+			Expect(finding.Blocking).To(BeFalse())
+			messages = append(messages, finding.Message)
+		}
+		Expect(messages).To(Equal([]string{
+			"v1.spec.size is now required",
+			"v1.spec.count was removed",
+			`value "small" is no longer allowed for v1.spec.size`,
+		}))
+	})
+
+	It("warns on newly restricted values, lengths and items", func() {
+		live := testCRD([]string{"v1"}, testVersion("v1", apiextensionsv1.JSONSchemaProps{
+			Type: "object",
+			Properties: map[string]apiextensionsv1.JSONSchemaProps{
+				"color": {Type: "string"},
+				"name":  {Type: "string", MinLength: ptr.To[int64](1)},
+				"tags":  {Type: "array", MinItems: ptr.To[int64](1), MaxItems: ptr.To[int64](10)},
+			},
+		}))
+		desired := testCRD(nil, testVersion("v1", apiextensionsv1.JSONSchemaProps{
+			Type: "object",
+			Properties: map[string]apiextensionsv1.JSONSchemaProps{
+				"color": {Type: "string", Enum: []apiextensionsv1.JSON{{Raw: []byte(`"red"`)}}},
+				"name":  {Type: "string", MinLength: ptr.To[int64](3)},
+				"tags":  {Type: "array", MinItems: ptr.To[int64](2), MaxItems: ptr.To[int64](5)},
+			},
+		}))
+		messages := []string{}
+		for _, finding := range CheckCRDUpgrade(live, desired) {
+			messages = append(messages, finding.Message)
+		}
+		Expect(messages).To(Equal([]string{
+			"values of v1.spec.color are now restricted to an enum",
+			"min length of v1.spec.name was increased",
+			"max items of v1.spec.tags was reduced",
+			"min items of v1.spec.tags was increased",
+		}))
+	})
+
+	It("allows relaxing lengths and items", func() {
+		live := testCRD([]string{"v1"}, testVersion("v1", apiextensionsv1.JSONSchemaProps{
+			Type: "object",
+			Properties: map[string]apiextensionsv1.JSONSchemaProps{
+				"name": {Type: "string", MinLength: ptr.To[int64](3)},
+				"tags": {Type: "array", MinItems: ptr.To[int64](2), MaxItems: ptr.To[int64](5)},
+			},
+		}))
+		desired := testCRD(nil, testVersion("v1", apiextensionsv1.JSONSchemaProps{
+			Type: "object",
+			Properties: map[string]apiextensionsv1.JSONSchemaProps{
+				"name": {Type: "string"},
+				"tags": {Type: "array", MinItems: ptr.To[int64](1), MaxItems: ptr.To[int64](10)},
+			},
+		}))
+		Expect(CheckCRDUpgrade(live, desired)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,48 @@
+package helm
+
+import (
+	"bytes"
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/postrender"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// crdGuard is a post-renderer which rejects a release whose templates contain a CRD which is not allowed, after the
+// post-renderer of the release ran. Together with skipping the crds directory, this makes sure that an install or
+// upgrade can't bypass InstallOptions.CRDAllowed
+type crdGuard struct {
+	next    postrender.PostRenderer
+	allowed func(crd *apiextensionsv1.CustomResourceDefinition) bool
+}
+
+func (g crdGuard) Run(manifests *bytes.Buffer) (*bytes.Buffer, error) {
+	if g.next != nil {
+		var err error
+		if manifests, err = g.next.Run(manifests); err != nil {
+			return nil, err
+		}
+	}
+	crds, _, err := ExtractCRDs(manifests.String())
+	if err != nil {
+		return nil, err
+	}
+	for _, crd := range crds {
+		if !g.allowed(crd) {
+			return nil, fmt.Errorf("%w: %s", ErrCRDNotAllowed, crd.Name)
+		}
+	}
+	return manifests, nil
+}
+
+// guardCRDs restricts the CRDs which an install or upgrade may apply to the ones allowed by opts.CRDAllowed. The
+// crds directory of the chart is skipped, since its CRDs are applied by InstallChartCRDs after they are checked
+func guardCRDs(opts InstallOptions) InstallOptions {
+	if opts.CRDAllowed == nil {
+		return opts
+	}
+	opts.Install.SkipCRDs = true
+	opts.Upgrade.SkipCRDs = true
+	opts.PostRenderer = crdGuard{next: opts.PostRenderer, allowed: opts.CRDAllowed}
+	return opts
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+type prependRenderer string
+
+func (p prependRenderer) Run(manifests *bytes.Buffer) (*bytes.Buffer, error) {
+	return bytes.NewBufferString(string(p) + manifests.String()), nil
+}
+
+var _ = Describe("guardCRDs", func() {
+	manifest, err := os.ReadFile(filepath.Join("testdata", "manifests", "crd-list.yaml"))
+	Expect(err).NotTo(HaveOccurred())
+	allowGroup := func(group string) func(*apiextensionsv1.CustomResourceDefinition) bool {
+		return func(crd *apiextensionsv1.CustomResourceDefinition) bool {
+			return crd.Spec.Group == group
+		}
+	}
+
+	It("should not change the options without CRDAllowed", func() {
+		opts := guardCRDs(InstallOptions{})
+		Expect(opts.PostRenderer).To(BeNil())
+		Expect(opts.Install.SkipCRDs).To(BeFalse())
+	})
+
+	It("should skip the crds directory on install and upgrade", func() {
+		opts := guardCRDs(InstallOptions{CRDAllowed: allowGroup("example.com")})
+		Expect(opts.Install.SkipCRDs).To(BeTrue())
+		Expect(opts.Upgrade.SkipCRDs).To(BeTrue())
+	})
+
+	It("should pass templates with allowed CRDs through", func() {
+		opts := guardCRDs(InstallOptions{CRDAllowed: allowGroup("example.com")})
+		out, err := opts.PostRenderer.Run(bytes.NewBuffer(manifest))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out.String()).To(Equal(string(manifest)))
+	})
+
+	It("should reject templates with CRDs which are not allowed", func() {
+		opts := guardCRDs(InstallOptions{CRDAllowed: allowGroup("other.example.com")})
+		_, err := opts.PostRenderer.Run(bytes.NewBuffer(manifest))
+		Expect(errors.Is(err, ErrCRDNotAllowed)).To(BeTrue())
+	})
+
+	It("should check the output of the post-renderer of the release", func() {
+		opts := guardCRDs(InstallOptions{
+			CRDAllowed:   allowGroup("other.example.com"),
+			PostRenderer: prependRenderer(string(manifest) + "\n---\n"),
+		})
+		_, err := opts.PostRenderer.Run(bytes.NewBufferString("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: example\n"))
+		Expect(errors.Is(err, ErrCRDNotAllowed)).To(BeTrue())
+	})
+})
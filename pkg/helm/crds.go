@@ -0,0 +1,141 @@
+package helm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsinstall "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/install"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var crdScheme = runtime.NewScheme()
+
+func init() {
+	apiextensionsinstall.Install(crdScheme)
+}
+
+// SkippedDocument is a document of a manifest which was not extracted as a CRD
+type SkippedDocument struct {
+	// Index is the position of the document in the manifest, starting at 0
+	Index int
+	// Source is the template which rendered the document, if known
+	Source string
+	// Reason is why the document was skipped
+	Reason string
+}
+
+func (s SkippedDocument) String() string {
+	if s.Source != "" {
+		return fmt.Sprintf("document %d (%s): %s", s.Index, s.Source, s.Reason)
+	}
+	return fmt.Sprintf("document %d: %s", s.Index, s.Reason)
+}
+
+// ExtractCRDs parses a multi-document YAML manifest and returns all of the CRDs in it.
+// CRDs wrapped in a List are extracted as well, and apiextensions.k8s.io/v1beta1 CRDs are converted to v1.
+// All other documents are returned with the reason they were skipped
+func ExtractCRDs(manifest string) ([]*apiextensionsv1.CustomResourceDefinition, []SkippedDocument, error) {
+	crds := []*apiextensionsv1.CustomResourceDefinition{}
+	skipped := []SkippedDocument{}
+	for _, document := range decodeDocuments(manifest) {
+		skip := func(reason string) {
+			skipped = append(skipped, SkippedDocument{Index: document.Index, Source: document.Source, Reason: reason})
+		}
+		if document.Err != nil {
+			skip(document.Err.Error())
+			continue
+		}
+		if document.Object == nil {
+			skip("empty document")
+			continue
+		}
+		objCRDs, reason, err := objectCRDs(document.Object)
+		if err != nil {
+			skip(err.Error())
+			continue
+		}
+		if reason != "" {
+			skip(reason)
+			continue
+		}
+		crds = append(crds, objCRDs...)
+	}
+	return crds, skipped, nil
+}
+
+// objectCRDs returns the CRDs of a single decoded object, or the reason it doesn't contain any CRDs
+func objectCRDs(u *unstructured.Unstructured) ([]*apiextensionsv1.CustomResourceDefinition, string, error) {
+	gvk := u.GroupVersionKind()
+
+	if u.IsList() {
+		list, err := u.ToList()
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid list: %w", err)
+		}
+		crds := []*apiextensionsv1.CustomResourceDefinition{}
+		reasons := []string{}
+		for i := range list.Items {
+			itemCRDs, reason, err := objectCRDs(&list.Items[i])
+			if err != nil {
+				return nil, "", fmt.Errorf("item %d of list: %w", i, err)
+			}
+			if reason != "" {
+				reasons = append(reasons, fmt.Sprintf("item %d: %s", i, reason))
+			}
+			crds = append(crds, itemCRDs...)
+		}
+		if len(crds) == 0 {
+			return nil, fmt.Sprintf("%s contains no CRDs (%s)", gvk.Kind, strings.Join(reasons, "; ")), nil
+		}
+		return crds, "", nil
+	}
+
+	if gvk.Group != apiextensionsv1.GroupName || gvk.Kind != "CustomResourceDefinition" {
+		return nil, fmt.Sprintf("%s %s is not a CRD", gvk.GroupVersion().String(), gvk.Kind), nil
+	}
+	raw, err := u.MarshalJSON()
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid CRD: %w", err)
+	}
+	switch gvk.Version {
+	case apiextensionsv1.SchemeGroupVersion.Version:
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err := json.Unmarshal(raw, crd); err != nil {
+			return nil, "", fmt.Errorf("invalid CRD: %w", err)
+		}
+		return []*apiextensionsv1.CustomResourceDefinition{crd}, "", nil
+	case apiextensionsv1beta1.SchemeGroupVersion.Version:
+		crd, err := convertV1beta1CRD(raw)
+		if err != nil {
+			return nil, "", err
+		}
+		return []*apiextensionsv1.CustomResourceDefinition{crd}, "", nil
+	default:
+		return nil, fmt.Sprintf("unsupported CRD version %s", gvk.Version), nil
+	}
+}
+
+// convertV1beta1CRD converts a json encoded apiextensions.k8s.io/v1beta1 CRD to v1
+func convertV1beta1CRD(raw []byte) (*apiextensionsv1.CustomResourceDefinition, error) {
+	v1beta1CRD := &apiextensionsv1beta1.CustomResourceDefinition{}
+	if err := json.Unmarshal(raw, v1beta1CRD); err != nil {
+		return nil, fmt.Errorf("invalid v1beta1 CRD: %w", err)
+	}
+	crdScheme.Default(v1beta1CRD)
+	internalCRD := &apiextensions.CustomResourceDefinition{}
+	if err := crdScheme.Convert(v1beta1CRD, internalCRD, nil); err != nil {
+		return nil, fmt.Errorf("failed to convert v1beta1 CRD: %w", err)
+	}
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := crdScheme.Convert(internalCRD, crd, nil); err != nil {
+		return nil, fmt.Errorf("failed to convert v1beta1 CRD: %w", err)
+	}
+	crd.APIVersion = apiextensionsv1.SchemeGroupVersion.String()
+	crd.Kind = "CustomResourceDefinition"
+	return crd, nil
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func crdNames(crds []*apiextensionsv1.CustomResourceDefinition) []string {
+	names := []string{}
+	for _, crd := range crds {
+		names = append(names, crd.Name)
+	}
+	return names
+}
+
+func skippedReasons(skipped []SkippedDocument) []string {
+	reasons := []string{}
+	for _, document := range skipped {
+		reasons = append(reasons, document.Reason)
+	}
+	return reasons
+}
+
+var _ = Describe("ExtractCRDs", func() {
+	DescribeTable("extracting CRDs from chart manifests",
+		func(file string, expectedCRDs []string, expectedSkipped []string) {
+			manifest, err := os.ReadFile(filepath.Join("testdata", "manifests", file))
+			Expect(err).NotTo(HaveOccurred())
+
+			crds, skipped, err := ExtractCRDs(string(manifest))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(crdNames(crds)).To(Equal(expectedCRDs))
+			Expect(skippedReasons(skipped)).To(Equal(expectedSkipped))
+		},
+		Entry("cert-manager with block scalars and separator comments", "cert-manager.yaml",
+			[]string{"issuers.cert-manager.io", "clusterissuers.cert-manager.io"},
+			[]string{
+				"v1 ServiceAccount is not a CRD",
+				"v1 ConfigMap is not a CRD",
+				"apps/v1 Deployment is not a CRD",
+			},
+		),
+		Entry("CRDs wrapped in a List", "crd-list.yaml",
+			[]string{"widgets.example.com"},
+			[]string{"List contains no CRDs (item 0: rbac.authorization.k8s.io/v1 ClusterRole is not a CRD)"},
+		),
+		Entry("chartmuseum as rendered by helm", "chartmuseum.yaml",
+			[]string{},
+			[]string{
+				"v1 Secret is not a CRD",
+				"v1 Service is not a CRD",
+				"extensions/v1beta1 Deployment is not a CRD",
+			},
+		),
+		Entry("apiextensions.k8s.io/v1beta1 CRDs", "v1beta1.yaml",
+			[]string{"gadgets.legacy.example.com"},
+			[]string{"empty document", "v1 Service is not a CRD"},
+		),
+	)
+
+	It("should keep block scalars containing document separators intact", func() {
+		manifest, err := os.ReadFile(filepath.Join("testdata", "manifests", "cert-manager.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+
+		crds, _, err := ExtractCRDs(string(manifest))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(crds[0].Spec.Versions[0].Schema.OpenAPIV3Schema.Description).To(ContainSubstring("---\nIt is scoped to a single namespace"))
+	})
+
+	It("should report the template each skipped document came from", func() {
+		manifest, err := os.ReadFile(filepath.Join("testdata", "manifests", "cert-manager.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+
+		_, skipped, err := ExtractCRDs(string(manifest))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(skipped[0].Index).To(Equal(0))
+		Expect(skipped[0].Source).To(Equal("cert-manager/templates/serviceaccount.yaml"))
+		Expect(skipped[2].String()).To(Equal("document 4 (cert-manager/templates/deployment.yaml): apps/v1 Deployment is not a CRD"))
+	})
+
+	It("should convert v1beta1 CRDs to v1", func() {
+		manifest, err := os.ReadFile(filepath.Join("testdata", "manifests", "v1beta1.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+
+		crds, _, err := ExtractCRDs(string(manifest))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(crds).To(HaveLen(1))
+		crd := crds[0]
+		Expect(crd.APIVersion).To(Equal("apiextensions.k8s.io/v1"))
+		Expect(crd.Spec.Group).To(Equal("legacy.example.com"))
+		Expect(crd.Spec.Versions).To(HaveLen(1))
+		Expect(crd.Spec.Versions[0].Name).To(Equal("v1beta1"))
+		Expect(crd.Spec.Versions[0].Served).To(BeTrue())
+		Expect(crd.Spec.Versions[0].Storage).To(BeTrue())
+		Expect(crd.Spec.Versions[0].Schema.OpenAPIV3Schema.Properties).To(HaveKey("spec"))
+	})
+
+	It("should keep every version of CRDs in a List", func() {
+		manifest, err := os.ReadFile(filepath.Join("testdata", "manifests", "crd-list.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+
+		crds, _, err := ExtractCRDs(string(manifest))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(crds[0].Spec.Versions).To(HaveLen(2))
+	})
+
+	It("should skip documents with invalid yaml and keep extracting", func() {
+		manifest, err := os.ReadFile(filepath.Join("testdata", "manifests", "chartmuseum.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		crd, err := os.ReadFile(filepath.Join("testdata", "manifests", "v1beta1.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		broken := "---\n# Source: chartmuseum/templates/broken.yaml\napiVersion: v1\nkind: [ConfigMap\n"
+
+		crds, skipped, err := ExtractCRDs(string(manifest) + broken + string(crd))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(crdNames(crds)).To(Equal([]string{"gadgets.legacy.example.com"}))
+		Expect(skipped[3].Index).To(Equal(3))
+		Expect(skipped[3].Source).To(Equal("chartmuseum/templates/broken.yaml"))
+		Expect(skipped[3].Reason).To(HavePrefix("invalid YAML:"))
+	})
+})
@@ -2,25 +2,32 @@ package helm
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"regexp"
 	"time"
 
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/postrender"
 	"helm.sh/helm/v3/pkg/registry"
 	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/runtime/serializer/yaml"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var (
-	yamlSep            = regexp.MustCompile(`(?m)^---`)
-	decodingSerializer = yaml.NewDecodingSerializer(unstructured.UnstructuredJSONScheme)
+	// ErrCRDNotAllowed is returned when a chart contains a CRD which is rejected by InstallOptions.CRDAllowed
+	ErrCRDNotAllowed = errors.New("crd is not allowed")
+	// ErrUnsafeCRDUpgrade is returned when applying the CRDs of a chart would remove versions which still have stored objects
+	ErrUnsafeCRDUpgrade = errors.New("unsafe crd upgrade")
 )
 
 type InstallOptions struct {
@@ -36,80 +43,199 @@ type InstallOptions struct {
 	Values map[string]interface{}
 	// CreateNamespace if true will create the namespace if it does not exist
 	CreateNamespace bool
+	// ServiceAccountName if set is the service account in Namespace which is impersonated when installing the chart
+	ServiceAccountName string
+	// CRDAllowed if set is called for every CRD in the chart before any of them are installed.
+	// If it returns false for any CRD, none of the CRDs are installed. It also makes installs and upgrades skip the
+	// crds directory of the chart, and fail if the templates contain a CRD which is not allowed
+	CRDAllowed func(crd *apiextensionsv1.CustomResourceDefinition) bool
+	// PostRenderer if set patches the rendered manifests on install, upgrade and when rendering the chart
+	PostRenderer postrender.PostRenderer
+	// Install configures the helm install when the release doesn't exist yet
+	Install ActionOptions
+	// Upgrade configures the helm upgrade when the release already exists
+	Upgrade ActionOptions
+}
+
+// FieldManager is the field manager which installs and upgrades write objects with. Without it, helm would use the
+// name of the binary
+const FieldManager = "kubehoist-helm"
+
+// DefaultTimeout is how long helm waits for an install, upgrade or uninstall if no timeout is set
+const DefaultTimeout = 10 * time.Minute
+
+// ActionOptions configure a helm install or upgrade. They are ignored when only rendering the chart
+type ActionOptions struct {
+	// Timeout defaults to DefaultTimeout if zero
+	Timeout time.Duration
+	// Wait for the resources to be ready
+	Wait bool
+	// WaitForJobs waits for jobs to complete as well, if Wait is set
+	WaitForJobs bool
+	// Atomic rolls back (or uninstalls, for an install) the release if it fails
+	Atomic bool
+	// DisableHooks skips running the chart's hooks
+	DisableHooks bool
+	// SkipCRDs skips installing the CRDs in the crds directory of the chart
+	SkipCRDs bool
+	// MaxHistory limits the number of revisions kept for the release. Only used for upgrades, 0 is unlimited
+	MaxHistory int
+}
+
+func (o ActionOptions) timeout() time.Duration {
+	if o.Timeout == 0 {
+		return DefaultTimeout
+	}
+	return o.Timeout
 }
 
 type HelmClient struct {
-	settings       *cli.EnvSettings
-	registryClient *registry.Client
-	log            action.DebugLog
+	// settings are only used for locating and caching charts. Kubernetes access goes through restClientGetter
+	settings         *cli.EnvSettings
+	restClientGetter *restClientGetter
+	registryClient   *registry.Client
+	log              action.DebugLog
 }
 
-// NewInstaller creates a new Helm backed Installer for repo resources.
-func NewHelmClient(log action.DebugLog) (*HelmClient, error) {
+// NewHelmClient creates a new Helm client which accesses kubernetes with the given rest config.
+// restMapper is optional, and if nil a discovery based RESTMapper is used.
+func NewHelmClient(config *rest.Config, restMapper meta.RESTMapper, log action.DebugLog) (*HelmClient, error) {
 	if log == nil {
 		// If no logger is provided, use a no-op logger.
 		log = func(format string, v ...interface{}) {}
 	}
+	kube.ManagedFieldsManager = FieldManager
 	registryClient, err := registry.NewClient(registry.ClientOptEnableCache(true))
 	if err != nil {
 		log(fmt.Sprintf("failed to create registry client: %v", err))
 		return nil, err
 	}
+	getter, err := newRESTClientGetter(config, restMapper)
+	if err != nil {
+		log(fmt.Sprintf("failed to create rest client getter: %v", err))
+		return nil, err
+	}
 	return &HelmClient{
-		registryClient: registryClient,
-		settings:       cli.New(),
-		log:            log,
+		registryClient:   registryClient,
+		restClientGetter: getter,
+		settings:         cli.New(),
+		log:              log,
 	}, nil
 }
 
-// InstallChart takes a repo's name and a chart name and installs it. If namespace is not empty
-// it will install into that namespace and create the namespace. Version is required.
+// NewOfflineHelmClient creates a Helm client without access to a cluster, which can only be used to render charts
+func NewOfflineHelmClient(log action.DebugLog) (*HelmClient, error) {
+	return NewHelmClient(&rest.Config{}, meta.NewDefaultRESTMapper(nil), log)
+}
+
+// InstallChart installs the chart with the given options. If the release already exists, it is upgraded instead.
 func (h *HelmClient) InstallChart(ctx context.Context, opts InstallOptions) error {
-	action, err := h.newInstallAction(opts, false)
+	opts = guardCRDs(opts)
+	actionConfig, err := h.newActionConfig(opts, false)
+	if err != nil {
+		return err
+	}
+	exists, err := releaseExists(actionConfig, opts.ReleaseName)
 	if err != nil {
 		return err
 	}
+	if exists {
+		return h.upgradeChart(ctx, actionConfig, opts)
+	}
 
-	_, err = h.runInstallAction(ctx, opts, action)
+	_, err = h.runInstallAction(ctx, opts, h.newInstallAction(actionConfig, opts, false))
 
 	return err
 }
 
-func (h *HelmClient) InstallChartCRDs(ctx context.Context, opts InstallOptions, kclient client.Client) ([]*schema.GroupVersionKind, error) {
-	action, err := h.newInstallAction(opts, true)
+// UninstallChart uninstalls the release with the given options. CRDs installed by InstallChartCRDs are kept.
+func (h *HelmClient) UninstallChart(opts InstallOptions) error {
+	actionConfig, err := h.newActionConfig(opts, false)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	client := action.NewUninstall(actionConfig)
+	client.Wait = true
+	client.Timeout = DefaultTimeout
+	client.IgnoreNotFound = true
+	if _, err := client.Run(opts.ReleaseName); err != nil {
+		return fmt.Errorf("failed to uninstall chart: %w", err)
+	}
+	return nil
+}
+
+// RenderChartCRDs renders the chart without installing anything and returns the CRDs in it, along with every document
+// which was skipped because it is not a CRD
+func (h *HelmClient) RenderChartCRDs(ctx context.Context, opts InstallOptions) ([]*apiextensionsv1.CustomResourceDefinition, []SkippedDocument, error) {
+	manifest, err := h.RenderChart(ctx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ExtractCRDs(manifest)
+}
+
+// RenderChart renders the chart without installing anything, and returns the manifest including any CRDs
+func (h *HelmClient) RenderChart(ctx context.Context, opts InstallOptions) (string, error) {
+	actionConfig, err := h.newActionConfig(opts, true)
+	if err != nil {
+		return "", err
 	}
 
 	// Note this isn't actually doing an install, it's equivalent to the `helm template` command
-	release, err := h.runInstallAction(ctx, opts, action)
+	release, err := h.runInstallAction(ctx, opts, h.newInstallAction(actionConfig, opts, true))
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	return release.Manifest, nil
+}
+
+// InstallChartCRDs renders the chart and applies all of the CRDs in it. Before anything is applied, changed CRDs are
+// checked against the installed CRDs. Any findings are returned, and if any of them are blocking, no CRDs are applied
+// and ErrUnsafeCRDUpgrade is returned.
+func (h *HelmClient) InstallChartCRDs(ctx context.Context, opts InstallOptions, kclient client.Client) ([]*schema.GroupVersionKind, []CRDUpgradeFinding, error) {
+	crds, skipped, err := h.RenderChartCRDs(ctx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, document := range skipped {
+		h.log(fmt.Sprintf("skipping %s", document))
+	}
+	for _, crd := range crds {
+		if opts.CRDAllowed != nil && !opts.CRDAllowed(crd) {
+			return nil, nil, fmt.Errorf("%w: %s", ErrCRDNotAllowed, crd.Name)
+		}
+	}
+
+	findings := []CRDUpgradeFinding{}
+	blocked := false
+	for _, crd := range crds {
+		live := &apiextensionsv1.CustomResourceDefinition{}
+		err := kclient.Get(ctx, client.ObjectKey{Name: crd.Name}, live)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get installed crd: %w", err)
+		}
+		for _, finding := range CheckCRDUpgrade(live, crd) {
+			h.log(fmt.Sprintf("crd upgrade check: %s", finding))
+			blocked = blocked || finding.Blocking
+			findings = append(findings, finding)
+		}
+	}
+	if blocked {
+		return nil, findings, ErrUnsafeCRDUpgrade
 	}
 
 	installedCRDs := []*schema.GroupVersionKind{}
-	for _, objYaml := range yamlSep.Split(release.Manifest, -1) {
-		crd := &apiextensionsv1.CustomResourceDefinition{}
-		_, gvk, err := decodingSerializer.Decode([]byte(objYaml), nil, crd)
+	for _, crd := range crds {
+		// now server-side apply the CRDs
+		err = applyCRD(ctx, kclient, crd, opts)
 		if err != nil {
-			h.log(fmt.Sprintf("failed to decode object. Will continue: %v", err))
-			continue
+			return nil, findings, fmt.Errorf("failed to apply crd: %w", err)
 		}
-		if gvk.Group == "apiextensions.k8s.io" && gvk.Kind == "CustomResourceDefinition" {
-			// Make sure we set the annotations expected by helm to 'adopt' them correctly later
-			if crd.Annotations == nil {
-				crd.Annotations = map[string]string{}
-			}
-			crd.Annotations["meta.helm.sh/release-name"] = opts.ReleaseName
-			crd.Annotations["meta.helm.sh/release-namespace"] = opts.Namespace
-			// now server-side apply the CRDs
-			err = kclient.Patch(ctx, crd, client.Apply, client.FieldOwner("kubehoist-controller"), client.ForceOwnership)
-			if err != nil {
-				return nil, fmt.Errorf("failed to apply crd: %w", err)
-			}
-			for _, version := range crd.Spec.Versions {
-				installedCRDs = append(installedCRDs, &schema.GroupVersionKind{Group: crd.Spec.Group, Version: version.Name, Kind: crd.Spec.Names.Kind})
-			}
+		for _, version := range crd.Spec.Versions {
+			installedCRDs = append(installedCRDs, &schema.GroupVersionKind{Group: crd.Spec.Group, Version: version.Name, Kind: crd.Spec.Names.Kind})
 		}
 	}
 	// TODO: Investigate CRD installation race condition: https://github.com/kubernetes/kubectl/issues/1117
@@ -117,23 +243,49 @@ func (h *HelmClient) InstallChartCRDs(ctx context.Context, opts InstallOptions,
 	// 	// TODO wait for the CRD to be ready by checking the condition estabilished status
 	// }
 
-	return installedCRDs, nil
+	return installedCRDs, findings, nil
 }
 
-func (h *HelmClient) newInstallAction(opts InstallOptions, template bool) (*action.Install, error) {
+// applyCRD server-side applies a CRD, with the annotations helm expects to 'adopt' it correctly later
+func applyCRD(ctx context.Context, kclient client.Client, crd *apiextensionsv1.CustomResourceDefinition, opts InstallOptions, applyOpts ...client.PatchOption) error {
+	if crd.Annotations == nil {
+		crd.Annotations = map[string]string{}
+	}
+	crd.Annotations["meta.helm.sh/release-name"] = opts.ReleaseName
+	crd.Annotations["meta.helm.sh/release-namespace"] = opts.Namespace
+	// Make sure helm never deletes the CRDs (and all of their custom resources) when the release is uninstalled
+	crd.Annotations["helm.sh/resource-policy"] = "keep"
+	applyOpts = append(applyOpts, client.FieldOwner("kubehoist-controller"), client.ForceOwnership)
+	return kclient.Patch(ctx, crd, client.Apply, applyOpts...)
+}
+
+func (h *HelmClient) newActionConfig(opts InstallOptions, template bool) (*action.Configuration, error) {
 	actionConfig := &action.Configuration{RegistryClient: h.registryClient}
-	if err := actionConfig.Init(h.settings.RESTClientGetter(), opts.Namespace, "", h.log); err != nil {
+	getter := h.restClientGetter.forNamespace(opts.Namespace)
+	if opts.ServiceAccountName != "" && !template {
+		getter = getter.forServiceAccount(opts.Namespace, opts.ServiceAccountName)
+	}
+	if err := actionConfig.Init(getter, opts.Namespace, "", h.log); err != nil {
 		return nil, fmt.Errorf("failed to initialize helm action config: %w", err)
 	}
+	return actionConfig, nil
+}
+
+func (h *HelmClient) newInstallAction(actionConfig *action.Configuration, opts InstallOptions, template bool) *action.Install {
 	client := action.NewInstall(actionConfig)
-	client.Wait = true
 	client.Namespace = opts.Namespace
 	client.ReleaseName = opts.ReleaseName
 	client.Version = opts.Version
 	client.CreateNamespace = opts.CreateNamespace
-	client.Timeout = 10 * time.Minute
+	client.PostRenderer = opts.PostRenderer
 	if !template {
 		client.DryRunOption = "none"
+		client.Timeout = opts.Install.timeout()
+		client.Wait = opts.Install.Wait
+		client.WaitForJobs = opts.Install.WaitForJobs
+		client.Atomic = opts.Install.Atomic
+		client.DisableHooks = opts.Install.DisableHooks
+		client.SkipCRDs = opts.Install.SkipCRDs
 	} else {
 		client.DryRunOption = "true"
 		client.DryRun = true
@@ -141,18 +293,37 @@ func (h *HelmClient) newInstallAction(opts InstallOptions, template bool) (*acti
 		client.ClientOnly = true
 	}
 
-	return client, nil
+	return client
 }
 
-func (h *HelmClient) runInstallAction(ctx context.Context, opts InstallOptions, action *action.Install) (*release.Release, error) {
-	chartPath, err := action.ChartPathOptions.LocateChart(opts.ChartName, h.settings)
+func (h *HelmClient) upgradeChart(ctx context.Context, actionConfig *action.Configuration, opts InstallOptions) error {
+	client := action.NewUpgrade(actionConfig)
+	client.Namespace = opts.Namespace
+	client.Version = opts.Version
+	client.PostRenderer = opts.PostRenderer
+	client.Timeout = opts.Upgrade.timeout()
+	client.Wait = opts.Upgrade.Wait
+	client.WaitForJobs = opts.Upgrade.WaitForJobs
+	client.Atomic = opts.Upgrade.Atomic
+	client.DisableHooks = opts.Upgrade.DisableHooks
+	client.SkipCRDs = opts.Upgrade.SkipCRDs
+	client.MaxHistory = opts.Upgrade.MaxHistory
+
+	ch, err := h.loadChart(&client.ChartPathOptions, opts.ChartName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to locate chart: %w", err)
+		return err
 	}
 
-	ch, err := loader.Load(chartPath)
+	if _, err := client.RunWithContext(ctx, opts.ReleaseName, ch, opts.Values); err != nil {
+		return fmt.Errorf("failed to upgrade chart: %w", err)
+	}
+	return nil
+}
+
+func (h *HelmClient) runInstallAction(ctx context.Context, opts InstallOptions, action *action.Install) (*release.Release, error) {
+	ch, err := h.loadChart(&action.ChartPathOptions, opts.ChartName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load chart: %w", err)
+		return nil, err
 	}
 
 	release, err := action.RunWithContext(ctx, ch, opts.Values)
@@ -162,3 +333,29 @@ func (h *HelmClient) runInstallAction(ctx context.Context, opts InstallOptions,
 
 	return release, nil
 }
+
+func (h *HelmClient) loadChart(chartPathOptions *action.ChartPathOptions, chartName string) (*chart.Chart, error) {
+	chartPath, err := chartPathOptions.LocateChart(chartName, h.settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate chart: %w", err)
+	}
+
+	ch, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart: %w", err)
+	}
+	return ch, nil
+}
+
+func releaseExists(actionConfig *action.Configuration, releaseName string) (bool, error) {
+	history := action.NewHistory(actionConfig)
+	history.Max = 1
+	_, err := history.Run(releaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get release history: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,192 @@
+package helm
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// manifestDocument is a single document of a multi-document YAML manifest
+type manifestDocument struct {
+	// Index is the position of the document in the manifest, starting at 0
+	Index int
+	// Source is the template which rendered the document, if known
+	Source string
+	// Object is the decoded document, or nil if the document is empty or could not be decoded
+	Object *unstructured.Unstructured
+	// Err is why the document could not be decoded
+	Err error
+}
+
+// decodeDocuments decodes every document of a multi-document YAML manifest. Documents are parsed on their own, so an
+// error only affects the document it is in
+func decodeDocuments(manifest string) []manifestDocument {
+	documents := []manifestDocument{}
+	for index, text := range splitDocuments(manifest) {
+		documents = append(documents, decodeDocument(index, text))
+	}
+	return documents
+}
+
+func decodeDocument(index int, text string) manifestDocument {
+	document := manifestDocument{Index: index}
+	node := &yaml.Node{}
+	if err := yaml.Unmarshal([]byte(text), node); err != nil {
+		document.Source = textSource(text)
+		document.Err = fmt.Errorf("invalid YAML: %w", err)
+		return document
+	}
+	document.Source = documentSource(node)
+	if len(node.Content) == 0 || node.Content[0].Tag == "!!null" {
+		return document
+	}
+	obj := map[string]interface{}{}
+	if err := node.Decode(&obj); err != nil {
+		document.Err = fmt.Errorf("not a kubernetes object: %w", err)
+		return document
+	}
+	// Round trip through json so that the object only contains json compatible types
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		document.Err = fmt.Errorf("not a kubernetes object: %w", err)
+		return document
+	}
+	u := &unstructured.Unstructured{}
+	if err := u.UnmarshalJSON(raw); err != nil {
+		document.Err = fmt.Errorf("not a kubernetes object: %w", err)
+		return document
+	}
+	document.Object = u
+	return document
+}
+
+// splitDocuments splits a multi-document YAML manifest into its documents, without parsing them
+func splitDocuments(manifest string) []string {
+	documents := []string{}
+	reader := newDocumentReader(strings.NewReader(manifest))
+	for {
+		document, err := reader.Read()
+		if err != nil {
+			// Reading from a string only fails at the end
+			return documents
+		}
+		documents = append(documents, document)
+	}
+}
+
+// documentReader reads the documents of a multi-document YAML stream one at a time, without parsing them. A document
+// starts at a "---" marker, which may be followed by the start of its content such as "--- |" or "--- !tag", and ends
+// at the next "---" marker or at a "..." end marker. Only comments before the first marker are not a document
+type documentReader struct {
+	reader *bufio.Reader
+	// next is the start marker of the next document, which was read along with the end of the previous one
+	next string
+	done bool
+}
+
+func newDocumentReader(r io.Reader) *documentReader {
+	return &documentReader{reader: bufio.NewReader(r)}
+}
+
+// Read returns the next document, including its start marker, or io.EOF once there are no more documents
+func (r *documentReader) Read() (string, error) {
+	if r.done {
+		return "", io.EOF
+	}
+	buffer := &strings.Builder{}
+	buffer.WriteString(r.next)
+	explicit := r.next != ""
+	r.next = ""
+	for {
+		line, err := r.reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		isDocument := explicit || !isBlankYAML(buffer.String())
+		switch {
+		case isDocumentMarker(line, "---"):
+			if isDocument {
+				r.next = line
+				return buffer.String(), nil
+			}
+			buffer.Reset()
+			buffer.WriteString(line)
+			explicit = true
+		case isDocumentMarker(line, "...") && strings.TrimSpace(strings.SplitN(line[3:], "#", 2)[0]) == "":
+			if isDocument {
+				return buffer.String(), nil
+			}
+			buffer.Reset()
+		default:
+			buffer.WriteString(line)
+		}
+		if err != nil {
+			r.done = true
+			if explicit || !isBlankYAML(buffer.String()) {
+				return buffer.String(), nil
+			}
+			return "", io.EOF
+		}
+	}
+}
+
+// isDocumentMarker returns true if the line starts with the given document marker, which has to be followed by
+// whitespace or the end of the line
+func isDocumentMarker(line, marker string) bool {
+	rest, ok := strings.CutPrefix(line, marker)
+	return ok && (rest == "" || strings.ContainsAny(rest[:1], " \t\r\n"))
+}
+
+// CountDocuments returns the number of documents in a multi-document YAML manifest. A List is a single document
+func CountDocuments(manifest string) int {
+	return len(splitDocuments(manifest))
+}
+
+func isBlankYAML(text string) bool {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// documentSource returns the template a document was rendered from, using the "# Source:" comment which helm adds
+func documentSource(document *yaml.Node) string {
+	// Depending on the document, the comment ends up on the document, its root node or the first key of the root node
+	comments := []string{document.HeadComment}
+	if len(document.Content) > 0 {
+		root := document.Content[0]
+		comments = append(comments, root.HeadComment)
+		if len(root.Content) > 0 {
+			comments = append(comments, root.Content[0].HeadComment)
+		}
+	}
+	for _, comment := range comments {
+		if source := commentSource(comment); source != "" {
+			return source
+		}
+	}
+	return ""
+}
+
+// textSource returns the template a document which could not be parsed was rendered from
+func textSource(text string) string {
+	return commentSource(text)
+}
+
+func commentSource(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if source, ok := strings.CutPrefix(strings.TrimSpace(line), "# Source:"); ok {
+			return strings.TrimSpace(source)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("decodeDocuments", func() {
+	kinds := func(manifest string) []string {
+		kinds := []string{}
+		for _, document := range decodeDocuments(manifest) {
+			switch {
+			case document.Err != nil:
+				kinds = append(kinds, "error")
+			case document.Object == nil:
+				kinds = append(kinds, "empty")
+			default:
+				kinds = append(kinds, document.Object.GetKind())
+			}
+		}
+		return kinds
+	}
+
+	DescribeTable("splitting documents at markers",
+		func(manifest string, expected []string) {
+			Expect(kinds(manifest)).To(Equal(expected))
+			Expect(CountDocuments(manifest)).To(Equal(len(expected)))
+		},
+		Entry("plain separators", "# header\n---\nkind: A\n---\nkind: B\n", []string{"A", "B"}),
+		Entry("a document without a separator", "kind: A\n", []string{"A"}),
+		Entry("separators with comments", "--- # first\nkind: A\n---   # second\nkind: B\n", []string{"A", "B"}),
+		Entry("empty documents", "---\n---\nkind: A\n", []string{"empty", "A"}),
+		Entry("a tag after the separator", "---\nkind: A\n--- !!map\nkind: B\n", []string{"A", "B"}),
+		Entry("a block scalar after the separator", "---\nkind: A\n--- |\n  text\n---\nkind: B\n", []string{"A", "error", "B"}),
+		Entry("content after the separator", "---\nkind: A\n--- kind: B\n---\nkind: C\n", []string{"A", "error", "C"}),
+		Entry("end markers", "kind: A\n...\n---\nkind: B\n... # done\nkind: C\n", []string{"A", "B", "C"}),
+		Entry("markers which are content", "kind: A\ndata: |\n  ----\n  ....\nname: ---x\n", []string{"A"}),
+	)
+
+	It("reports a document which is not YAML by its index and keeps decoding", func() {
+		documents := decodeDocuments("---\nkind: A\n--- key: v\n# Source: chart/templates/b.yaml\n---\nkind: C\n")
+		Expect(documents).To(HaveLen(3))
+		Expect(documents[1].Index).To(Equal(1))
+		Expect(documents[1].Source).To(Equal("chart/templates/b.yaml"))
+		Expect(documents[1].Err).To(MatchError(HavePrefix("invalid YAML:")))
+		Expect(documents[2].Index).To(Equal(2))
+		Expect(documents[2].Object.GetKind()).To(Equal("C"))
+	})
+})
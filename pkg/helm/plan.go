@@ -0,0 +1,149 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type PlanAction string
+
+const (
+	PlanActionCreate    PlanAction = "Create"
+	PlanActionUpdate    PlanAction = "Update"
+	PlanActionUnchanged PlanAction = "Unchanged"
+)
+
+// CRDPlan is what applying a CRD would do
+type CRDPlan struct {
+	Name     string
+	Action   PlanAction
+	Findings []CRDUpgradeFinding
+}
+
+// PlanCRDs server-side dry-run applies the CRDs to find out which of them would be created or changed, without
+// changing anything in the cluster
+func PlanCRDs(ctx context.Context, kclient client.Client, crds []*apiextensionsv1.CustomResourceDefinition, opts InstallOptions) ([]CRDPlan, error) {
+	plans := []CRDPlan{}
+	for _, crd := range crds {
+		plan := CRDPlan{Name: crd.Name, Action: PlanActionCreate}
+		live := &apiextensionsv1.CustomResourceDefinition{}
+		err := kclient.Get(ctx, client.ObjectKey{Name: crd.Name}, live)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("failed to get installed crd: %w", err)
+		}
+		if err == nil {
+			plan.Findings = CheckCRDUpgrade(live, crd)
+		}
+
+		applied := crd.DeepCopy()
+		if err := applyCRD(ctx, kclient, applied, opts, client.DryRunAll); err != nil {
+			return nil, fmt.Errorf("failed to dry-run apply crd %s: %w", crd.Name, err)
+		}
+		if live.UID != "" {
+			// The generation is only incremented when the spec changes
+			plan.Action = PlanActionUnchanged
+			if applied.Generation != live.Generation {
+				plan.Action = PlanActionUpdate
+			}
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// ResourcePlan is what applying a resource of the chart would do
+type ResourcePlan struct {
+	Resource *unstructured.Unstructured
+	Action   PlanAction
+	// Err is why the server rejected the dry-run apply
+	Err error
+}
+
+// PlanResources server-side dry-run applies the resources of a chart to find out which of them would be created or
+// changed, and whether the server accepts them, without changing anything in the cluster. Namespaced resources without
+// a namespace are planned in the given namespace
+func PlanResources(ctx context.Context, kclient client.Client, resources []*unstructured.Unstructured, namespace string) []ResourcePlan {
+	plans := []ResourcePlan{}
+	for _, resource := range resources {
+		applied := resource.DeepCopy()
+		if applied.GetNamespace() == "" {
+			// Kinds which aren't known yet (i.e. custom resources of the chart's own CRDs) are assumed to be namespaced
+			if namespaced, err := kclient.IsObjectNamespaced(applied); err != nil || namespaced {
+				applied.SetNamespace(namespace)
+			}
+		}
+		plan := ResourcePlan{Resource: applied.DeepCopy()}
+		plan.Action, plan.Err = planResource(ctx, kclient, applied)
+		plans = append(plans, plan)
+	}
+	return plans
+}
+
+func planResource(ctx context.Context, kclient client.Client, applied *unstructured.Unstructured) (PlanAction, error) {
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(applied.GroupVersionKind())
+	err := kclient.Get(ctx, client.ObjectKeyFromObject(applied), live)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return "", err
+	}
+	exists := err == nil
+	if err := kclient.Patch(ctx, applied, client.Apply, client.DryRunAll, client.FieldOwner(FieldManager), client.ForceOwnership); err != nil {
+		return "", err
+	}
+	if !exists {
+		return PlanActionCreate, nil
+	}
+	if resourceChanged(live, applied) {
+		return PlanActionUpdate, nil
+	}
+	return PlanActionUnchanged, nil
+}
+
+// resourceChanged compares a live resource with the result of dry-run applying it
+func resourceChanged(live, applied *unstructured.Unstructured) bool {
+	if live.GetGeneration() != 0 {
+		// The generation is only incremented when the spec changes
+		return applied.GetGeneration() != live.GetGeneration()
+	}
+	// Kinds without a generation, such as ConfigMaps, are compared without the fields which the server maintains
+	strip := func(u *unstructured.Unstructured) map[string]interface{} {
+		u = u.DeepCopy()
+		unstructured.RemoveNestedField(u.Object, "metadata", "managedFields")
+		unstructured.RemoveNestedField(u.Object, "metadata", "resourceVersion")
+		unstructured.RemoveNestedField(u.Object, "status")
+		return u.Object
+	}
+	return !equality.Semantic.DeepEqual(strip(live), strip(applied))
+}
+
+// ExtractResources parses a multi-document YAML manifest and returns every object in it. Lists are expanded into their
+// items and empty documents are ignored
+func ExtractResources(manifest string) ([]*unstructured.Unstructured, error) {
+	resources := []*unstructured.Unstructured{}
+	for _, document := range decodeDocuments(manifest) {
+		if document.Err != nil {
+			return nil, fmt.Errorf("document %d of manifest: %w", document.Index, document.Err)
+		}
+		if document.Object == nil {
+			continue
+		}
+		if !document.Object.IsList() {
+			resources = append(resources, document.Object)
+			continue
+		}
+		list, err := document.Object.ToList()
+		if err != nil {
+			return nil, fmt.Errorf("document %d is an invalid list: %w", document.Index, err)
+		}
+		for i := range list.Items {
+			resources = append(resources, &list.Items[i])
+		}
+	}
+	return resources, nil
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+var _ = Describe("ExtractResources", func() {
+	It("expands lists and skips empty documents", func() {
+		manifest, err := os.ReadFile(filepath.Join("testdata", "manifests", "crd-list.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+
+		resources, err := ExtractResources(string(manifest) + "\n---\n# Source: example/templates/empty.yaml\n")
+		Expect(err).NotTo(HaveOccurred())
+		kinds := []string{}
+		for _, resource := range resources {
+			kinds = append(kinds, resource.GetKind())
+		}
+		Expect(kinds).To(Equal([]string{"CustomResourceDefinition", "ConfigMap", "ClusterRole"}))
+	})
+})
+
+var _ = Describe("PlanResources", func() {
+	It("dry-run applies every resource and finds what would change", func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		live := []client.Object{
+			&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "example", Name: "changed", Generation: 1},
+				Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](1)},
+			},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "example", Name: "same"}, Data: map[string]string{"a": "b"}},
+		}
+		// The fake client doesn't support server-side apply, so the dry-run is answered like the API server would
+		restMapper := meta.NewDefaultRESTMapper(nil)
+		restMapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+		restMapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+		restMapper.Add(rbacv1.SchemeGroupVersion.WithKind("ClusterRole"), meta.RESTScopeRoot)
+		kclient := fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(restMapper).WithObjects(live...).WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				Expect(patch.Type()).To(Equal(types.ApplyPatchType))
+				Expect(opts).To(ContainElement(client.DryRunAll))
+				applied := obj.(*unstructured.Unstructured)
+				if applied.GetKind() == "Widget" {
+					return &meta.NoKindMatchError{GroupKind: applied.GroupVersionKind().GroupKind()}
+				}
+				existing := &unstructured.Unstructured{}
+				existing.SetGroupVersionKind(applied.GroupVersionKind())
+				if err := c.Get(ctx, client.ObjectKeyFromObject(applied), existing); err != nil {
+					return client.IgnoreNotFound(err)
+				}
+				// The response is the live object with the applied fields, and a new generation if the spec changed
+				response := existing.DeepCopy()
+				for field, value := range applied.Object {
+					if field != "metadata" {
+						response.Object[field] = value
+					}
+				}
+				if existing.GetGeneration() != 0 && !equality.Semantic.DeepEqual(existing.Object["spec"], applied.Object["spec"]) {
+					response.SetGeneration(existing.GetGeneration() + 1)
+				}
+				response.DeepCopyInto(applied)
+				return nil
+			},
+		}).Build()
+
+		resources, err := ExtractResources(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: changed
+spec:
+  replicas: 2
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: same
+data:
+  a: b
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: new
+  namespace: other
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: new
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: default
+`)
+		Expect(err).NotTo(HaveOccurred())
+		plans := PlanResources(context.Background(), kclient, resources, "example")
+		Expect(plans).To(HaveLen(5))
+		summary := []string{}
+		for _, plan := range plans {
+			summary = append(summary, fmt.Sprintf("%s %s/%s %s %t", plan.Resource.GetKind(), plan.Resource.GetNamespace(), plan.Resource.GetName(), plan.Action, plan.Err != nil))
+		}
+		Expect(summary).To(Equal([]string{
+			"Deployment example/changed Update false",
+			"ConfigMap example/same Unchanged false",
+			"ConfigMap other/new Create false",
+			"ClusterRole /new Create false",
+			"Widget example/default  true",
+		}))
+	})
+})
@@ -0,0 +1,76 @@
+package helm
+
+import (
+	"errors"
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// ReleaseRecovery is what RecoverPendingRelease did to a release
+type ReleaseRecovery string
+
+const (
+	// ReleaseRecoveryNone means the release didn't need to be recovered
+	ReleaseRecoveryNone ReleaseRecovery = ""
+	// ReleaseRecoveryRolledBack means a pending upgrade or rollback was rolled back to the last deployed revision
+	ReleaseRecoveryRolledBack ReleaseRecovery = "RolledBack"
+	// ReleaseRecoveryUninstalled means a pending install without any earlier revision was uninstalled
+	ReleaseRecoveryUninstalled ReleaseRecovery = "Uninstalled"
+)
+
+// RecoverPendingRelease cleans up a release which was left in a pending state, i.e. because the process installing it
+// was killed. Helm refuses to touch a pending release again, so it is rolled back to its last deployed revision, or
+// uninstalled if there is none. Releases which aren't pending are left alone
+func (h *HelmClient) RecoverPendingRelease(opts InstallOptions) (ReleaseRecovery, error) {
+	actionConfig, err := h.newActionConfig(opts, false)
+	if err != nil {
+		return ReleaseRecoveryNone, err
+	}
+	return recoverPendingRelease(actionConfig, opts)
+}
+
+func recoverPendingRelease(actionConfig *action.Configuration, opts InstallOptions) (ReleaseRecovery, error) {
+	history, err := actionConfig.Releases.History(opts.ReleaseName)
+	if errors.Is(err, driver.ErrReleaseNotFound) || (err == nil && len(history) == 0) {
+		return ReleaseRecoveryNone, nil
+	}
+	if err != nil {
+		return ReleaseRecoveryNone, fmt.Errorf("failed to get release history: %w", err)
+	}
+	var last, lastDeployed *release.Release
+	for _, rel := range history {
+		if last == nil || rel.Version > last.Version {
+			last = rel
+		}
+		if rel.Info != nil && (rel.Info.Status == release.StatusDeployed || rel.Info.Status == release.StatusSuperseded) {
+			if lastDeployed == nil || rel.Version > lastDeployed.Version {
+				lastDeployed = rel
+			}
+		}
+	}
+	if last.Info == nil || !last.Info.Status.IsPending() {
+		return ReleaseRecoveryNone, nil
+	}
+
+	if lastDeployed == nil {
+		client := action.NewUninstall(actionConfig)
+		client.Timeout = opts.Install.timeout()
+		client.DisableHooks = true
+		if _, err := client.Run(opts.ReleaseName); err != nil {
+			return ReleaseRecoveryNone, fmt.Errorf("failed to uninstall pending release: %w", err)
+		}
+		return ReleaseRecoveryUninstalled, nil
+	}
+	client := action.NewRollback(actionConfig)
+	client.Version = lastDeployed.Version
+	client.Timeout = opts.Upgrade.timeout()
+	client.DisableHooks = true
+	client.MaxHistory = opts.Upgrade.MaxHistory
+	if err := client.Run(opts.ReleaseName); err != nil {
+		return ReleaseRecoveryNone, fmt.Errorf("failed to roll back pending release: %w", err)
+	}
+	return ReleaseRecoveryRolledBack, nil
+}
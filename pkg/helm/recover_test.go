@@ -0,0 +1,78 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helm
+
+import (
+	"io"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+var _ = Describe("RecoverPendingRelease", func() {
+	var actionConfig *action.Configuration
+	opts := InstallOptions{ReleaseName: "example", Namespace: "default"}
+
+	addRelease := func(version int, status release.Status) {
+		Expect(actionConfig.Releases.Create(&release.Release{
+			Name:      opts.ReleaseName,
+			Namespace: opts.Namespace,
+			Version:   version,
+			Info:      &release.Info{Status: status},
+			Chart:     &chart.Chart{Metadata: &chart.Metadata{Name: "example", Version: "1.0.0", APIVersion: chart.APIVersionV2}},
+		})).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		actionConfig = &action.Configuration{
+			Releases:     storage.Init(driver.NewMemory()),
+			KubeClient:   &kubefake.PrintingKubeClient{Out: io.Discard},
+			Capabilities: chartutil.DefaultCapabilities,
+			Log:          func(string, ...interface{}) {},
+		}
+	})
+
+	It("leaves missing and deployed releases alone", func() {
+		Expect(recoverPendingRelease(actionConfig, opts)).To(Equal(ReleaseRecoveryNone))
+		addRelease(1, release.StatusDeployed)
+		Expect(recoverPendingRelease(actionConfig, opts)).To(Equal(ReleaseRecoveryNone))
+	})
+
+	It("uninstalls a pending install", func() {
+		addRelease(1, release.StatusPendingInstall)
+		Expect(recoverPendingRelease(actionConfig, opts)).To(Equal(ReleaseRecoveryUninstalled))
+		_, err := actionConfig.Releases.History(opts.ReleaseName)
+		Expect(err).To(MatchError(driver.ErrReleaseNotFound))
+	})
+
+	It("rolls a pending upgrade back to the deployed revision", func() {
+		addRelease(1, release.StatusDeployed)
+		addRelease(2, release.StatusPendingUpgrade)
+		Expect(recoverPendingRelease(actionConfig, opts)).To(Equal(ReleaseRecoveryRolledBack))
+		last, err := actionConfig.Releases.Last(opts.ReleaseName)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(last.Version).To(Equal(3))
+		Expect(last.Info.Status).To(Equal(release.StatusDeployed))
+	})
+})
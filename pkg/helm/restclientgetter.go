@@ -0,0 +1,79 @@
+package helm
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// restClientGetter is a genericclioptions.RESTClientGetter backed by an existing rest config, so that helm
+// talks to the same cluster with the same rate limits and user agent as the rest of kubehoist
+type restClientGetter struct {
+	config          *rest.Config
+	discoveryClient discovery.CachedDiscoveryInterface
+	restMapper      meta.RESTMapper
+	namespace       string
+}
+
+var _ genericclioptions.RESTClientGetter = &restClientGetter{}
+
+// newRESTClientGetter creates a RESTClientGetter for the given rest config. If restMapper is nil, a discovery based
+// RESTMapper is created instead.
+func newRESTClientGetter(config *rest.Config, restMapper meta.RESTMapper) (*restClientGetter, error) {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+	cachedDiscoveryClient := memory.NewMemCacheClient(discoveryClient)
+	if restMapper == nil {
+		deferredMapper := restmapper.NewDeferredDiscoveryRESTMapper(cachedDiscoveryClient)
+		restMapper = restmapper.NewShortcutExpander(deferredMapper, cachedDiscoveryClient, nil)
+	}
+	return &restClientGetter{
+		config:          config,
+		discoveryClient: cachedDiscoveryClient,
+		restMapper:      restMapper,
+	}, nil
+}
+
+// forNamespace returns a copy of this getter which defaults to the given namespace
+func (r *restClientGetter) forNamespace(namespace string) *restClientGetter {
+	getter := *r
+	getter.namespace = namespace
+	return &getter
+}
+
+// forServiceAccount returns a copy of this getter which impersonates the given service account.
+// Discovery and REST mapping are shared with the original getter, since they are not identity specific
+func (r *restClientGetter) forServiceAccount(namespace, serviceAccountName string) *restClientGetter {
+	getter := *r
+	getter.config = rest.CopyConfig(r.config)
+	getter.config.Impersonate = rest.ImpersonationConfig{
+		UserName: fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccountName),
+	}
+	return &getter
+}
+
+func (r *restClientGetter) ToRESTConfig() (*rest.Config, error) {
+	return rest.CopyConfig(r.config), nil
+}
+
+func (r *restClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	return r.discoveryClient, nil
+}
+
+func (r *restClientGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	return r.restMapper, nil
+}
+
+func (r *restClientGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	overrides := &clientcmd.ConfigOverrides{Context: clientcmdapi.Context{Namespace: r.namespace}}
+	return clientcmd.NewDefaultClientConfig(*clientcmdapi.NewConfig(), overrides)
+}
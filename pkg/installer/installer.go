@@ -0,0 +1,325 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+)
+
+const (
+	// Subcommand is the subcommand of the manager binary which runs an install inside a Job
+	Subcommand = "kubehoist-installer"
+	// RequestPath is where the Request is mounted into the installer from the Secret of its Job
+	RequestPath = requestDir + "/" + requestKey
+	requestDir  = "/etc/kubehoist-installer"
+	requestKey  = "request.json"
+	homeDir     = "/home/kubehoist-installer"
+	// ControllerWatchLabel is set on installer Jobs to the name of the ControllerWatch they install
+	ControllerWatchLabel = "kubehoist.io/controllerwatch"
+	// logTailLines is how many lines of the installer logs are collected
+	logTailLines = 50
+	// logLimitBytes is the maximum size of the installer logs which are collected
+	logLimitBytes = 4096
+	// podDeletionTimeout is how long DeleteJobs waits for the pods of the deleted Jobs to stop
+	podDeletionTimeout = 2 * time.Minute
+)
+
+// Request is everything the installer needs to install a chart
+type Request struct {
+	// Spec of the ControllerWatch which is installed
+	Spec controllerv1alpha1.ControllerWatchSpec `json:"spec"`
+	// Namespace of the NamespacedControllerWatch which is installed, which restricts the install like in the manager.
+	// Empty for a ControllerWatch
+	Namespace string `json:"namespace,omitempty"`
+	// AllowedCRDGroups are the CRD groups which a NamespacedControllerWatch may install
+	AllowedCRDGroups []string `json:"allowedCRDGroups,omitempty"`
+}
+
+// ReadRequest reads the Request which is mounted into the installer
+func ReadRequest(path string) (*Request, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read install request: %w", err)
+	}
+	request := &Request{}
+	if err := json.Unmarshal(data, request); err != nil {
+		return nil, fmt.Errorf("invalid install request %s: %w", path, err)
+	}
+	return request, nil
+}
+
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete;deletecollection
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods/log,verbs=get
+
+// JobRunner runs installs in Kubernetes Jobs, so that a chart which needs a lot of memory to render can't take down
+// the manager
+type JobRunner struct {
+	Client client.Client
+	// Reader reads Jobs and pods directly from the API server, so that they don't need to be cached
+	Reader    client.Reader
+	Clientset kubernetes.Interface
+	// Image is the image of the manager, which runs the installer subcommand
+	Image string
+	// Namespace which the Jobs are created in
+	Namespace string
+	// ServiceAccountName which the Jobs run as. It needs the same permissions as the manager to install charts
+	ServiceAccountName string
+	// PollInterval is how often the Job is checked for completion
+	PollInterval time.Duration
+}
+
+// Run creates an installer Job for the request and waits for it to finish. It returns the tail of the installer logs,
+// and an error with the termination message of the installer if the install failed. If the context is cancelled, the
+// Job is deleted. The request, which may contain credentials in the helm values, is passed in a Secret owned by the Job
+func (j *JobRunner) Run(ctx context.Context, controllerWatch client.ObjectKey, request *Request) (string, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	name := "kubehoist-install-" + utilrand.String(5)
+	labels := map[string]string{ControllerWatchLabel: controllerWatchLabelValue(controllerWatch)}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: j.Namespace, Labels: labels},
+		Data:       map[string][]byte{requestKey: data},
+	}
+	if err := j.Client.Create(ctx, secret); err != nil {
+		return "", fmt.Errorf("failed to create installer secret: %w", err)
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: j.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            ptr.To[int32](0),
+			TTLSecondsAfterFinished: ptr.To[int32](3600),
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:      corev1.RestartPolicyNever,
+					ServiceAccountName: j.ServiceAccountName,
+					SecurityContext: &corev1.PodSecurityContext{
+						RunAsNonRoot:   ptr.To(true),
+						SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+					},
+					Containers: []corev1.Container{{
+						Name:  "installer",
+						Image: j.Image,
+						Args:  []string{Subcommand},
+						// helm keeps its cache and config under the home directory
+						Env: []corev1.EnvVar{{Name: "HOME", Value: homeDir}},
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: "request", MountPath: requestDir, ReadOnly: true},
+							{Name: "home", MountPath: homeDir},
+						},
+						SecurityContext: &corev1.SecurityContext{
+							AllowPrivilegeEscalation: ptr.To(false),
+							ReadOnlyRootFilesystem:   ptr.To(true),
+							Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+						},
+						Resources: corev1.ResourceRequirements{
+							Limits: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("500m"),
+								corev1.ResourceMemory: resource.MustParse("128Mi"),
+							},
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("10m"),
+								corev1.ResourceMemory: resource.MustParse("64Mi"),
+							},
+						},
+						TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+					}},
+					Volumes: []corev1.Volume{
+						{
+							Name:         "request",
+							VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: name}},
+						},
+						{
+							Name:         "home",
+							VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+						},
+					},
+				},
+			},
+		},
+	}
+	if err := j.Client.Create(ctx, job); err != nil {
+		deleteErr := j.Client.Delete(context.Background(), secret)
+		return "", errors.Join(fmt.Errorf("failed to create installer job: %w", err), client.IgnoreNotFound(deleteErr))
+	}
+	// The Secret is deleted along with the Job
+	patch := client.MergeFrom(secret.DeepCopy())
+	if err := controllerutil.SetOwnerReference(job, secret, j.Client.Scheme()); err == nil {
+		err = j.Client.Patch(ctx, secret, patch)
+	}
+	if err != nil {
+		deleteErr := j.Client.Delete(context.Background(), job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		return "", errors.Join(fmt.Errorf("failed to set owner of installer secret: %w", err), client.IgnoreNotFound(deleteErr),
+			client.IgnoreNotFound(j.Client.Delete(context.Background(), secret)))
+	}
+
+	failed, err := j.wait(ctx, job)
+	if err != nil {
+		if ctx.Err() != nil {
+			// Don't leave an outdated install running in the background
+			deleteErr := j.Client.Delete(context.Background(), job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+			return "", errors.Join(err, client.IgnoreNotFound(deleteErr))
+		}
+		return "", err
+	}
+
+	logs, message := j.collect(ctx, job)
+	if failed {
+		if message == "" {
+			message = "unknown error"
+		}
+		return logs, fmt.Errorf("installer job %s failed: %s", job.Name, message)
+	}
+	return logs, nil
+}
+
+// DeleteJobs deletes every installer Job of a ControllerWatch, i.e. Jobs which were left running by a previous leader.
+// It waits until their pods stopped running, so that they don't write to the release anymore
+func (j *JobRunner) DeleteJobs(ctx context.Context, controllerWatch client.ObjectKey) error {
+	labels := client.MatchingLabels{ControllerWatchLabel: controllerWatchLabelValue(controllerWatch)}
+	if err := j.Client.DeleteAllOf(ctx, &batchv1.Job{}, client.InNamespace(j.Namespace), labels,
+		client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil {
+		return err
+	}
+	err := wait.PollUntilContextTimeout(ctx, j.pollInterval(), podDeletionTimeout, true, func(ctx context.Context) (bool, error) {
+		pods := &corev1.PodList{}
+		if err := j.Reader.List(ctx, pods, client.InNamespace(j.Namespace), labels); err != nil {
+			// Retried on the next poll
+			return false, nil
+		}
+		for _, pod := range pods.Items {
+			if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed waiting for the pods of installer jobs to stop: %w", err)
+	}
+	return nil
+}
+
+func (j *JobRunner) pollInterval() time.Duration {
+	if j.PollInterval == 0 {
+		return 5 * time.Second
+	}
+	return j.PollInterval
+}
+
+// wait polls the Job until it is complete or failed, and returns true if it failed
+func (j *JobRunner) wait(ctx context.Context, job *batchv1.Job) (bool, error) {
+	ticker := time.NewTicker(j.pollInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-ticker.C:
+		}
+		if err := j.Reader.Get(ctx, client.ObjectKeyFromObject(job), job); err != nil {
+			if apierrors.IsNotFound(err) {
+				return false, fmt.Errorf("installer job %s was deleted", job.Name)
+			}
+			// The install keeps running in the Job, so transient errors are retried on the next poll
+			continue
+		}
+		for _, condition := range job.Status.Conditions {
+			if condition.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch condition.Type {
+			case batchv1.JobComplete:
+				return false, nil
+			case batchv1.JobFailed:
+				return true, nil
+			}
+		}
+	}
+}
+
+// collect returns the tail of the logs and the termination message of the last pod of the Job. These are best
+// effort, since the pod may already be gone
+func (j *JobRunner) collect(ctx context.Context, job *batchv1.Job) (string, string) {
+	pods := &corev1.PodList{}
+	if err := j.Reader.List(ctx, pods, client.InNamespace(job.Namespace), client.MatchingLabels{batchv1.JobNameLabel: job.Name}); err != nil || len(pods.Items) == 0 {
+		return "", ""
+	}
+	pod := slices.MaxFunc(pods.Items, func(a, b corev1.Pod) int {
+		return a.CreationTimestamp.Compare(b.CreationTimestamp.Time)
+	})
+	message := ""
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated != nil {
+			message = strings.TrimSpace(status.State.Terminated.Message)
+		}
+	}
+	stream, err := j.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		TailLines:  ptr.To[int64](logTailLines),
+		LimitBytes: ptr.To[int64](logLimitBytes),
+	}).Stream(ctx)
+	if err != nil {
+		return "", message
+	}
+	defer stream.Close()
+	logs, _ := io.ReadAll(stream)
+	return string(logs), message
+}
+
+// controllerWatchLabelValue is a label value identifying a ControllerWatch, since a key with a namespace is not a valid
+// label value
+func controllerWatchLabelValue(key client.ObjectKey) string {
+	value := key.Name
+	if key.Namespace != "" {
+		value = key.Namespace + "." + key.Name
+	}
+	if len(value) > 63 {
+		value = value[:63]
+	}
+	return strings.Trim(value, "-_.")
+}
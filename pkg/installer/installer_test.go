@@ -0,0 +1,214 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+)
+
+var _ = Describe("Installer", func() {
+	It("reads a request from a file", func() {
+		_, err := ReadRequest(filepath.Join(GinkgoT().TempDir(), "missing.json"))
+		Expect(err).To(HaveOccurred())
+
+		path := filepath.Join(GinkgoT().TempDir(), "request.json")
+		Expect(os.WriteFile(path, []byte(`{"spec":{"helmSpec":{"releaseName":"test"}}}`), 0o600)).To(Succeed())
+		request, err := ReadRequest(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(request.Spec.HelmControllerSpec.ReleaseName).To(Equal("test"))
+	})
+
+	Context("JobRunner", func() {
+		var kclient client.Client
+		var runner *JobRunner
+
+		BeforeEach(func() {
+			scheme := runtime.NewScheme()
+			Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			kclient = fake.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(&batchv1.Job{}).Build()
+			runner = &JobRunner{
+				Client:             kclient,
+				Reader:             kclient,
+				Clientset:          kubefake.NewSimpleClientset(),
+				Image:              "kubehoist:test",
+				Namespace:          "kubehoist-system",
+				ServiceAccountName: "installer",
+				PollInterval:       10 * time.Millisecond,
+			}
+		})
+
+		// finishJob waits for the installer job to be created, and marks it with the given condition
+		finishJob := func(conditionType batchv1.JobConditionType, message string) *batchv1.Job {
+			job := &batchv1.Job{}
+			Eventually(func(g Gomega) {
+				jobs := &batchv1.JobList{}
+				g.Expect(kclient.List(context.Background(), jobs)).To(Succeed())
+				g.Expect(jobs.Items).To(HaveLen(1))
+				*job = jobs.Items[0]
+			}).Should(Succeed())
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      job.Name + "-pod",
+					Namespace: job.Namespace,
+					Labels:    map[string]string{batchv1.JobNameLabel: job.Name},
+				},
+				Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+					Name:  "installer",
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Message: message}},
+				}}},
+			}
+			Expect(kclient.Create(context.Background(), pod)).To(Succeed())
+			job.Status.Conditions = []batchv1.JobCondition{{Type: conditionType, Status: corev1.ConditionTrue}}
+			Expect(kclient.Status().Update(context.Background(), job)).To(Succeed())
+			return job
+		}
+
+		It("creates a job running the installer and waits for it to complete", func() {
+			request := &Request{Spec: controllerv1alpha1.ControllerWatchSpec{HelmControllerSpec: controllerv1alpha1.HelmInstallSpec{ReleaseName: "test"}}}
+			done := make(chan error)
+			go func() {
+				_, err := runner.Run(context.Background(), client.ObjectKey{Namespace: "default", Name: "test"}, request)
+				done <- err
+			}()
+			job := finishJob(batchv1.JobComplete, "installed")
+			Eventually(done).Should(Receive(BeNil()))
+
+			Expect(job.Labels).To(HaveKeyWithValue(ControllerWatchLabel, "default.test"))
+			container := job.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).To(Equal("kubehoist:test"))
+			Expect(container.Args).To(Equal([]string{Subcommand}))
+			Expect(container.Env).To(ConsistOf(HaveField("Name", "HOME")))
+			Expect(container.VolumeMounts).To(ContainElement(HaveField("MountPath", filepath.Dir(RequestPath))))
+			Expect(job.Spec.Template.Spec.ServiceAccountName).To(Equal("installer"))
+
+			By("running the job as restricted as the manager")
+			Expect(*job.Spec.Template.Spec.SecurityContext.RunAsNonRoot).To(BeTrue())
+			Expect(*container.SecurityContext.ReadOnlyRootFilesystem).To(BeTrue())
+			Expect(*container.SecurityContext.AllowPrivilegeEscalation).To(BeFalse())
+			Expect(container.SecurityContext.Capabilities.Drop).To(ConsistOf(corev1.Capability("ALL")))
+			Expect(container.Resources.Limits).To(HaveKey(corev1.ResourceMemory))
+			Expect(container.Resources.Requests).To(HaveKey(corev1.ResourceCPU))
+
+			By("passing the request in a secret owned by the job")
+			secret := &corev1.Secret{}
+			Expect(kclient.Get(context.Background(), client.ObjectKeyFromObject(job), secret)).To(Succeed())
+			Expect(string(secret.Data[filepath.Base(RequestPath)])).To(ContainSubstring(`"releaseName":"test"`))
+			Expect(secret.OwnerReferences).To(ConsistOf(HaveField("Name", job.Name)))
+			Expect(job.Spec.Template.Spec.Volumes).To(ContainElement(HaveField("Secret", HaveField("SecretName", secret.Name))))
+		})
+
+		It("returns the termination message of a failed job", func() {
+			done := make(chan error)
+			go func() {
+				_, err := runner.Run(context.Background(), client.ObjectKey{Name: "test"}, &Request{})
+				done <- err
+			}()
+			finishJob(batchv1.JobFailed, "chart not found")
+			var err error
+			Eventually(done).Should(Receive(&err))
+			Expect(err).To(MatchError(ContainSubstring("chart not found")))
+		})
+
+		It("keeps waiting for the job when getting it fails", func() {
+			failures := atomic.Int32{}
+			failures.Store(3)
+			runner.Reader = interceptor.NewClient(kclient.(client.WithWatch), interceptor.Funcs{
+				Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+					if _, ok := obj.(*batchv1.Job); ok && failures.Load() > 0 {
+						failures.Add(-1)
+						return errors.New("connection refused")
+					}
+					return c.Get(ctx, key, obj, opts...)
+				},
+			})
+			done := make(chan error)
+			go func() {
+				_, err := runner.Run(context.Background(), client.ObjectKey{Name: "test"}, &Request{})
+				done <- err
+			}()
+			Eventually(failures.Load).Should(BeZero())
+			finishJob(batchv1.JobComplete, "installed")
+			Eventually(done).Should(Receive(BeNil()))
+		})
+
+		It("waits for the pods of deleted jobs to stop", func() {
+			key := client.ObjectKey{Namespace: "default", Name: "test"}
+			labels := map[string]string{ControllerWatchLabel: "default.test"}
+			job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "install", Namespace: "kubehoist-system", Labels: labels}}
+			running := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "kubehoist-system", Labels: labels},
+				Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+			}
+			finished := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "finished", Namespace: "kubehoist-system", Labels: labels},
+				Status:     corev1.PodStatus{Phase: corev1.PodFailed},
+			}
+			for _, obj := range []client.Object{job, running, finished} {
+				Expect(kclient.Create(context.Background(), obj)).To(Succeed())
+			}
+			done := make(chan error)
+			go func() {
+				done <- runner.DeleteJobs(context.Background(), key)
+			}()
+			Consistently(done, 100*time.Millisecond).ShouldNot(Receive())
+			Expect(kclient.Get(context.Background(), client.ObjectKeyFromObject(job), job)).NotTo(Succeed())
+
+			Expect(kclient.Delete(context.Background(), running)).To(Succeed())
+			Eventually(done).Should(Receive(BeNil()))
+		})
+
+		It("deletes the job when cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() {
+				_, err := runner.Run(ctx, client.ObjectKey{Name: "test"}, &Request{})
+				done <- err
+			}()
+			Eventually(func(g Gomega) {
+				jobs := &batchv1.JobList{}
+				g.Expect(kclient.List(context.Background(), jobs)).To(Succeed())
+				g.Expect(jobs.Items).To(HaveLen(1))
+			}).Should(Succeed())
+			cancel()
+			var err error
+			Eventually(done).Should(Receive(&err))
+			Expect(err).To(MatchError(context.Canceled))
+			jobs := &batchv1.JobList{}
+			Expect(kclient.List(context.Background(), jobs)).To(Succeed())
+			Expect(jobs.Items).To(BeEmpty())
+		})
+	})
+})
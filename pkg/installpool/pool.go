@@ -0,0 +1,238 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installpool
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type State string
+
+const (
+	StateQueued    State = "Queued"
+	StateRunning   State = "Running"
+	StateSucceeded State = "Succeeded"
+	StateFailed    State = "Failed"
+)
+
+// Status is the progress of a job
+type Status struct {
+	// Generation of the object which the job was submitted for
+	Generation int64
+	State      State
+	QueuedAt   time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// Output is anything the job reported, i.e. logs
+	Output string
+	// Err is the error returned by a failed job
+	Err error
+}
+
+// Finished returns true if the job succeeded or failed
+func (s Status) Finished() bool {
+	return s.State == StateSucceeded || s.State == StateFailed
+}
+
+// Pool runs jobs in the background with a bounded number of workers. There is at most one job per key, and the
+// results of finished jobs are kept until they are forgotten, so that they can be polled from a reconcile loop.
+// Jobs are only cancelled while they are queued. Interrupting a helm operation leaves its release pending, so a job
+// which already started runs to completion, and jobs with the same lock wait for it before they start
+type Pool struct {
+	slots chan struct{}
+
+	mu   sync.Mutex
+	ctx  context.Context
+	jobs map[string]*job
+	// locks holds a channel for every lock with a running job, which is closed once the job finished
+	locks map[string]chan struct{}
+}
+
+type job struct {
+	status  Status
+	lock    string
+	started bool
+	cancel  context.CancelFunc
+	notify  func()
+}
+
+// New creates a pool which runs up to workers jobs at the same time
+func New(workers int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Pool{
+		slots: make(chan struct{}, workers),
+		ctx:   context.Background(),
+		jobs:  map[string]*job{},
+		locks: map[string]chan struct{}{},
+	}
+}
+
+// Start implements manager.Runnable. All jobs are cancelled when the context is done
+func (p *Pool) Start(ctx context.Context) error {
+	p.mu.Lock()
+	p.ctx = ctx
+	p.mu.Unlock()
+	<-ctx.Done()
+	return nil
+}
+
+// Submit queues work under key, replacing any other job for the same key. Jobs with the same lock never run at the
+// same time, and an empty lock defaults to the key. notify is called without any locks held whenever the job starts
+// or finishes
+func (p *Pool) Submit(key, lock string, generation int64, work func(ctx context.Context) (string, error), notify func()) {
+	if lock == "" {
+		lock = key
+	}
+	p.mu.Lock()
+	if existing, ok := p.jobs[key]; ok {
+		existing.cancelQueued()
+	}
+	ctx, cancel := context.WithCancel(p.ctx)
+	j := &job{
+		status: Status{Generation: generation, State: StateQueued, QueuedAt: time.Now()},
+		lock:   lock,
+		cancel: cancel,
+		notify: notify,
+	}
+	p.jobs[key] = j
+	p.mu.Unlock()
+
+	go p.run(ctx, key, j, work)
+}
+
+func (p *Pool) run(ctx context.Context, key string, j *job, work func(ctx context.Context) (string, error)) {
+	defer j.cancel()
+	done, err := p.acquire(ctx, j)
+	if err != nil {
+		p.update(key, j, func(status *Status) {
+			status.State = StateFailed
+			status.FinishedAt = time.Now()
+			status.Err = err
+		})
+		return
+	}
+	defer p.release(j, done)
+
+	p.update(key, j, func(status *Status) {
+		status.State = StateRunning
+		status.StartedAt = time.Now()
+	})
+	output, err := work(ctx)
+	p.update(key, j, func(status *Status) {
+		status.State = StateSucceeded
+		if err != nil {
+			status.State = StateFailed
+		}
+		status.FinishedAt = time.Now()
+		status.Output = output
+		status.Err = err
+	})
+}
+
+// acquire waits until no other job holds the lock of the job and a worker is free, and then marks the job as started
+// so that it isn't cancelled anymore. The returned channel has to be passed to release
+func (p *Pool) acquire(ctx context.Context, j *job) (chan struct{}, error) {
+	for {
+		p.mu.Lock()
+		held, ok := p.locks[j.lock]
+		p.mu.Unlock()
+		if ok {
+			select {
+			case <-held:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		p.mu.Lock()
+		if err := ctx.Err(); err != nil {
+			p.mu.Unlock()
+			<-p.slots
+			return nil, err
+		}
+		if _, ok := p.locks[j.lock]; ok {
+			// Another job took the lock while this one waited for a worker
+			p.mu.Unlock()
+			<-p.slots
+			continue
+		}
+		done := make(chan struct{})
+		p.locks[j.lock] = done
+		j.started = true
+		p.mu.Unlock()
+		return done, nil
+	}
+}
+
+func (p *Pool) release(j *job, done chan struct{}) {
+	p.mu.Lock()
+	delete(p.locks, j.lock)
+	p.mu.Unlock()
+	close(done)
+	<-p.slots
+}
+
+// cancelQueued cancels the job if it didn't start yet. It must be called with the lock of the pool held
+func (j *job) cancelQueued() {
+	if !j.started {
+		j.cancel()
+	}
+}
+
+// update changes the status of a job and notifies, unless the job was cancelled and replaced or forgotten
+func (p *Pool) update(key string, j *job, change func(status *Status)) {
+	p.mu.Lock()
+	current := p.jobs[key] == j
+	if current {
+		change(&j.status)
+	}
+	p.mu.Unlock()
+	if current && j.notify != nil {
+		j.notify()
+	}
+}
+
+// Status returns the status of the job for key, if there is one
+func (p *Pool) Status(key string) (Status, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	j, ok := p.jobs[key]
+	if !ok {
+		return Status{}, false
+	}
+	return j.status, true
+}
+
+// Remove forgets the job for key, cancelling it if it is still queued. A running job keeps holding its lock until it
+// finished
+func (p *Pool) Remove(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if j, ok := p.jobs[key]; ok {
+		j.cancelQueued()
+		delete(p.jobs, key)
+	}
+}
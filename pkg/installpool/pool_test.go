@@ -0,0 +1,151 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package installpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func state(p *Pool, key string) State {
+	status, ok := p.Status(key)
+	if !ok {
+		return ""
+	}
+	return status.State
+}
+
+var _ = Describe("Pool", func() {
+	It("reports the result of a job", func() {
+		p := New(1)
+		notified := make(chan struct{}, 10)
+		p.Submit("ok", "", 1, func(ctx context.Context) (string, error) { return "", nil }, func() { notified <- struct{}{} })
+		p.Submit("failed", "", 1, func(ctx context.Context) (string, error) { return "some logs", errors.New("boom") }, nil)
+
+		Eventually(func() State { return state(p, "ok") }).Should(Equal(StateSucceeded))
+		Eventually(func() State { return state(p, "failed") }).Should(Equal(StateFailed))
+		status, _ := p.Status("failed")
+		Expect(status.Err).To(MatchError("boom"))
+		Expect(status.Output).To(Equal("some logs"))
+		// Once when it starts and once when it finishes
+		Eventually(func() int { return len(notified) }).Should(Equal(2))
+
+		p.Remove("ok")
+		_, ok := p.Status("ok")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("runs at most the given number of jobs at the same time", func() {
+		p := New(2)
+		release := make(chan struct{})
+		var running, maxRunning atomic.Int32
+		for i := range 5 {
+			p.Submit(fmt.Sprint(i), "", 1, func(ctx context.Context) (string, error) {
+				n := running.Add(1)
+				for {
+					current := maxRunning.Load()
+					if n <= current || maxRunning.CompareAndSwap(current, n) {
+						break
+					}
+				}
+				<-release
+				running.Add(-1)
+				return "", nil
+			}, nil)
+		}
+		Eventually(running.Load).Should(Equal(int32(2)))
+		Consistently(running.Load).Should(Equal(int32(2)))
+		close(release)
+		for i := range 5 {
+			Eventually(func() State { return state(p, fmt.Sprint(i)) }).Should(Equal(StateSucceeded))
+		}
+		Expect(maxRunning.Load()).To(Equal(int32(2)))
+	})
+
+	It("lets a running install finish when it is replaced, and installs again afterwards", func() {
+		p := New(2)
+		release := make(chan struct{})
+		var cancelled, reinstalledEarly atomic.Bool
+		var firstDone atomic.Bool
+		p.Submit("key", "", 1, func(ctx context.Context) (string, error) {
+			select {
+			case <-release:
+			case <-ctx.Done():
+				cancelled.Store(true)
+			}
+			firstDone.Store(true)
+			return "", nil
+		}, nil)
+		Eventually(func() State { return state(p, "key") }).Should(Equal(StateRunning))
+
+		// i.e. the spec changed partway through the install
+		p.Remove("key")
+		p.Submit("key", "", 2, func(ctx context.Context) (string, error) {
+			reinstalledEarly.Store(!firstDone.Load())
+			return "", nil
+		}, nil)
+		Consistently(func() State { return state(p, "key") }).Should(Equal(StateQueued))
+		close(release)
+		Eventually(func() State { return state(p, "key") }).Should(Equal(StateSucceeded))
+		status, _ := p.Status("key")
+		Expect(status.Generation).To(Equal(int64(2)))
+		Expect(cancelled.Load()).To(BeFalse())
+		Expect(reinstalledEarly.Load()).To(BeFalse())
+	})
+
+	It("cancels a queued job when it is replaced", func() {
+		p := New(1)
+		release := make(chan struct{})
+		p.Submit("blocking", "", 1, func(ctx context.Context) (string, error) {
+			<-release
+			return "", nil
+		}, nil)
+		Eventually(func() State { return state(p, "blocking") }).Should(Equal(StateRunning))
+
+		var ran atomic.Bool
+		p.Submit("key", "", 1, func(ctx context.Context) (string, error) {
+			ran.Store(true)
+			return "", nil
+		}, nil)
+		p.Submit("key", "", 2, func(ctx context.Context) (string, error) { return "", nil }, nil)
+		close(release)
+		Eventually(func() State { return state(p, "key") }).Should(Equal(StateSucceeded))
+		status, _ := p.Status("key")
+		Expect(status.Generation).To(Equal(int64(2)))
+		Expect(ran.Load()).To(BeFalse())
+	})
+
+	It("never runs jobs with the same lock at the same time", func() {
+		p := New(2)
+		release := make(chan struct{})
+		p.Submit("install", "release", 1, func(ctx context.Context) (string, error) {
+			<-release
+			return "", nil
+		}, nil)
+		Eventually(func() State { return state(p, "install") }).Should(Equal(StateRunning))
+
+		p.Submit("sleep", "release", 1, func(ctx context.Context) (string, error) { return "", nil }, nil)
+		Consistently(func() State { return state(p, "sleep") }).Should(Equal(StateQueued))
+		close(release)
+		Eventually(func() State { return state(p, "sleep") }).Should(Equal(StateSucceeded))
+	})
+})
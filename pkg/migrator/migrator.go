@@ -0,0 +1,130 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const defaultPageSize = 500
+
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions/status,verbs=update
+
+// Migrator rewrites all of the objects of a CRD so that they are stored in the current storage version of the CRD
+type Migrator struct {
+	// Client must read objects directly from the API server, not from a cache
+	Client client.Client
+	// PageSize is the number of objects listed at a time. Defaults to 500
+	PageSize int64
+}
+
+// StorageVersion returns the version which objects of the CRD are currently stored as
+func StorageVersion(crd *apiextensionsv1.CustomResourceDefinition) string {
+	for _, version := range crd.Spec.Versions {
+		if version.Storage {
+			return version.Name
+		}
+	}
+	return ""
+}
+
+// NeedsMigration returns true if the CRD may still have objects stored in a version other than its storage version
+func NeedsMigration(crd *apiextensionsv1.CustomResourceDefinition) bool {
+	storageVersion := StorageVersion(crd)
+	return storageVersion != "" && slices.ContainsFunc(crd.Status.StoredVersions, func(v string) bool { return v != storageVersion })
+}
+
+// Migrate rewrites every object of the CRD with a no-op update, so that the API server stores it in the current storage
+// version, then trims status.storedVersions of the CRD down to only the storage version.
+// progress is called with the number of objects migrated so far after every page of objects
+func (m *Migrator) Migrate(ctx context.Context, crd *apiextensionsv1.CustomResourceDefinition, progress func(migrated int) error) (int, error) {
+	storageVersion := StorageVersion(crd)
+	if storageVersion == "" {
+		return 0, fmt.Errorf("crd %s has no storage version", crd.Name)
+	}
+	pageSize := m.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	listGVK := schema.GroupVersionKind{Group: crd.Spec.Group, Version: storageVersion, Kind: crd.Spec.Names.ListKind}
+	if listGVK.Kind == "" {
+		listGVK.Kind = crd.Spec.Names.Kind + "List"
+	}
+
+	migrated := 0
+	continueToken := ""
+	for {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(listGVK)
+		if err := m.Client.List(ctx, list, client.Limit(pageSize), client.Continue(continueToken)); err != nil {
+			return migrated, fmt.Errorf("failed to list %s: %w", crd.Name, err)
+		}
+		for i := range list.Items {
+			if err := m.migrateObject(ctx, &list.Items[i]); err != nil {
+				return migrated, err
+			}
+			migrated++
+		}
+		if progress != nil {
+			if err := progress(migrated); err != nil {
+				return migrated, err
+			}
+		}
+		continueToken = list.GetContinue()
+		if continueToken == "" {
+			break
+		}
+	}
+
+	return migrated, m.trimStoredVersions(ctx, crd.Name, storageVersion)
+}
+
+func (m *Migrator) migrateObject(ctx context.Context, obj *unstructured.Unstructured) error {
+	err := m.Client.Update(ctx, obj)
+	// A conflict means the object was written since it was listed, so it is already stored in the storage version.
+	// Objects which were deleted since they were listed don't need to be migrated either
+	if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to migrate %s %s: %w", obj.GetKind(), client.ObjectKeyFromObject(obj), err)
+	}
+	return nil
+}
+
+func (m *Migrator) trimStoredVersions(ctx context.Context, name, storageVersion string) error {
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := m.Client.Get(ctx, client.ObjectKey{Name: name}, crd); err != nil {
+		return fmt.Errorf("failed to get crd %s: %w", name, err)
+	}
+	if StorageVersion(crd) != storageVersion {
+		return fmt.Errorf("storage version of crd %s changed during migration", name)
+	}
+	crd.Status.StoredVersions = []string{storageVersion}
+	if err := m.Client.Status().Update(ctx, crd); err != nil {
+		return fmt.Errorf("failed to update stored versions of crd %s: %w", name, err)
+	}
+	return nil
+}
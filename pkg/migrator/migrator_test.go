@@ -0,0 +1,163 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+var widgetGVK = schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+
+func widgetCRD(storedVersions ...string) *apiextensionsv1.CustomResourceDefinition {
+	return &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "widgets.example.com"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "example.com",
+			Names: apiextensionsv1.CustomResourceDefinitionNames{Kind: "Widget", ListKind: "WidgetList", Plural: "widgets"},
+			Scope: apiextensionsv1.NamespaceScoped,
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{Name: "v1alpha1", Served: true},
+				{Name: "v1", Served: true, Storage: true},
+			},
+		},
+		Status: apiextensionsv1.CustomResourceDefinitionStatus{StoredVersions: storedVersions},
+	}
+}
+
+func widget(name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(widgetGVK)
+	obj.SetNamespace("default")
+	obj.SetName(name)
+	return obj
+}
+
+var _ = Describe("Migrator", func() {
+	It("detects CRDs which need a migration", func() {
+		Expect(NeedsMigration(widgetCRD("v1alpha1", "v1"))).To(BeTrue())
+		Expect(NeedsMigration(widgetCRD("v1alpha1"))).To(BeTrue())
+		Expect(NeedsMigration(widgetCRD("v1"))).To(BeFalse())
+	})
+
+	It("rewrites every object and trims the stored versions", func() {
+		scheme := runtime.NewScheme()
+		Expect(apiextensionsv1.AddToScheme(scheme)).To(Succeed())
+		restMapper := meta.NewDefaultRESTMapper(nil)
+		restMapper.Add(widgetGVK, meta.RESTScopeNamespace)
+
+		crd := widgetCRD("v1alpha1", "v1")
+		objects := []client.Object{crd}
+		for i := range 5 {
+			objects = append(objects, widget(fmt.Sprintf("widget-%d", i)))
+		}
+		fakeClient := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithRESTMapper(restMapper).
+			WithObjects(objects...).
+			WithStatusSubresource(crd).
+			Build()
+
+		before := widget("widget-0")
+		Expect(fakeClient.Get(context.Background(), client.ObjectKeyFromObject(before), before)).To(Succeed())
+
+		progress := []int{}
+		m := &Migrator{Client: fakeClient, PageSize: 2}
+		migrated, err := m.Migrate(context.Background(), crd, func(migrated int) error {
+			progress = append(progress, migrated)
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(migrated).To(Equal(5))
+		Expect(progress).To(HaveExactElements(5))
+
+		updated := &apiextensionsv1.CustomResourceDefinition{}
+		Expect(fakeClient.Get(context.Background(), client.ObjectKey{Name: crd.Name}, updated)).To(Succeed())
+		Expect(updated.Status.StoredVersions).To(Equal([]string{"v1"}))
+
+		after := widget("widget-0")
+		Expect(fakeClient.Get(context.Background(), client.ObjectKeyFromObject(after), after)).To(Succeed())
+		Expect(after.GetResourceVersion()).NotTo(Equal(before.GetResourceVersion()))
+	})
+
+	It("follows the continue token of paginated lists", func() {
+		scheme := runtime.NewScheme()
+		Expect(apiextensionsv1.AddToScheme(scheme)).To(Succeed())
+		restMapper := meta.NewDefaultRESTMapper(nil)
+		restMapper.Add(widgetGVK, meta.RESTScopeNamespace)
+
+		crd := widgetCRD("v1alpha1", "v1")
+		objects := []client.Object{crd}
+		for i := range 5 {
+			objects = append(objects, widget(fmt.Sprintf("widget-%d", i)))
+		}
+		// The fake client ignores the limit, so pages are cut like the API server does, with the offset as the token
+		continueTokens := []string{}
+		fakeClient := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithRESTMapper(restMapper).
+			WithObjects(objects...).
+			WithStatusSubresource(crd).
+			WithInterceptorFuncs(interceptor.Funcs{
+				List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+					listOpts := &client.ListOptions{}
+					listOpts.ApplyOptions(opts)
+					continueTokens = append(continueTokens, listOpts.Continue)
+					if err := c.List(ctx, list); err != nil {
+						return err
+					}
+					widgets := list.(*unstructured.UnstructuredList)
+					offset := 0
+					if listOpts.Continue != "" {
+						offset, _ = strconv.Atoi(listOpts.Continue)
+					}
+					total := len(widgets.Items)
+					end := min(offset+int(listOpts.Limit), total)
+					widgets.Items = widgets.Items[offset:end]
+					if end < total {
+						widgets.SetContinue(strconv.Itoa(end))
+					}
+					return nil
+				},
+			}).
+			Build()
+
+		progress := []int{}
+		m := &Migrator{Client: fakeClient, PageSize: 2}
+		migrated, err := m.Migrate(context.Background(), crd, func(migrated int) error {
+			progress = append(progress, migrated)
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(migrated).To(Equal(5))
+		Expect(progress).To(HaveExactElements(2, 4, 5))
+		Expect(continueTokens).To(HaveExactElements("", "2", "4"))
+	})
+})
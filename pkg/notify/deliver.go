@@ -0,0 +1,224 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+)
+
+const (
+	// SignatureHeader is the HMAC-SHA256 of the body as sha256=<hex>, when the HoistNotifier has a signing key
+	SignatureHeader = "X-Kubehoist-Signature"
+	// EventHeader is the installation status which the controller transitioned into
+	EventHeader = "X-Kubehoist-Event"
+	// DeliveryHeader is unique for every notification, and the same for every attempt to deliver it
+	DeliveryHeader = "X-Kubehoist-Delivery"
+
+	// maxPayloadLength is how much of the payload of a dead letter is kept in the status
+	maxPayloadLength = 4096
+)
+
+// permanentError is a failed delivery which is not retried, because it would fail the same way again
+type permanentError struct {
+	error
+}
+
+// deliver POSTs a notification to a HoistNotifier, retrying until MaxAttempts, and records the result in its status
+func (n *Notifier) deliver(ctx context.Context, notifier *controllerv1alpha1.HoistNotifier, notification Notification) error {
+	log := log.FromContext(ctx).WithValues("HoistNotifier", notifier.Name, "ControllerWatch", notification.ControllerWatch(), "event", notification.Event)
+	payload, err := Render(notifier.Spec.PayloadTemplate, notification)
+	if err != nil {
+		return n.deadLetter(ctx, notifier, notification, payload, 0, err)
+	}
+	var key []byte
+	if ref := notifier.Spec.SigningKeySecretRef; ref != nil {
+		if key, err = n.signingKey(ctx, ref); err != nil {
+			return n.deadLetter(ctx, notifier, notification, payload, 0, err)
+		}
+	}
+
+	maxAttempts := notifier.Spec.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	delivery := string(uuid.NewUUID())
+	backoff := n.Backoff
+	attempts := int32(0)
+	for {
+		attempts++
+		err = n.post(ctx, notifier.Spec.URL, notification, delivery, payload, key)
+		if err == nil {
+			log.V(1).Info("Delivered notification", "attempts", attempts)
+			return n.patchStatus(ctx, notifier, func(status *controllerv1alpha1.HoistNotifierStatus) {
+				status.Delivered++
+				status.LastDelivered = &metav1.Time{Time: time.Now()}
+			})
+		}
+		var permanent permanentError
+		if attempts >= maxAttempts || errors.As(err, &permanent) {
+			break
+		}
+		log.V(1).Info("Retrying notification", "attempts", attempts, "error", err.Error())
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff.Step()):
+		}
+	}
+	log.Info("Giving up notification", "attempts", attempts, "error", err.Error())
+	return n.deadLetter(ctx, notifier, notification, payload, attempts, err)
+}
+
+// post makes a single attempt to deliver a notification. Server errors, rate limiting and connection errors are retried
+func (n *Notifier) post(ctx context.Context, url string, notification Notification, delivery string, payload, key []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "kubehoist")
+	req.Header.Set(EventHeader, string(notification.Event))
+	req.Header.Set(DeliveryHeader, delivery)
+	if key != nil {
+		req.Header.Set(SignatureHeader, Sign(key, payload))
+	}
+	resp, err := n.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("webhook responded with %s: %s", resp.Status, bytes.TrimSpace(body))
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return err
+	}
+	return permanentError{err}
+}
+
+// signingKey reads a signing key from the namespace of kubehoist, which is the only namespace it can read secrets in
+func (n *Notifier) signingKey(ctx context.Context, ref *controllerv1alpha1.SecretKeyReference) ([]byte, error) {
+	if n.Namespace == "" {
+		return nil, errors.New("failed to get signing key: the namespace of kubehoist is unknown")
+	}
+	secret := &corev1.Secret{}
+	if err := n.Reader.Get(ctx, client.ObjectKey{Namespace: n.Namespace, Name: ref.Name}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get signing key: %w", err)
+	}
+	key, ok := secret.Data[ref.Key]
+	if !ok || len(key) == 0 {
+		return nil, fmt.Errorf("signing key %s not found in secret %s/%s", ref.Key, n.Namespace, ref.Name)
+	}
+	return key, nil
+}
+
+// deadLetter records a notification which could not be delivered in the status of the HoistNotifier
+func (n *Notifier) deadLetter(ctx context.Context, notifier *controllerv1alpha1.HoistNotifier, notification Notification, payload []byte, attempts int32, err error) error {
+	if ctx.Err() != nil {
+		// The manager is stopping, which is not a failure of the webhook
+		return ctx.Err()
+	}
+	if len(payload) > maxPayloadLength {
+		payload = payload[:maxPayloadLength]
+	}
+	deadLetter := controllerv1alpha1.DeadLetter{
+		Event:           notification.Event,
+		ControllerWatch: notification.ControllerWatch(),
+		Time:            metav1.Now(),
+		Attempts:        attempts,
+		Error:           err.Error(),
+		Payload:         string(payload),
+	}
+	return n.patchStatus(ctx, notifier, func(status *controllerv1alpha1.HoistNotifierStatus) {
+		status.Failed++
+		status.DeadLetters = append(status.DeadLetters, deadLetter)
+		if len(status.DeadLetters) > controllerv1alpha1.MaxDeadLetters {
+			status.DeadLetters = status.DeadLetters[len(status.DeadLetters)-controllerv1alpha1.MaxDeadLetters:]
+		}
+	})
+}
+
+// patchStatus applies a change to the status of a HoistNotifier, retrying on conflicts since several notifications
+// can be delivered to it at the same time
+func (n *Notifier) patchStatus(ctx context.Context, notifier *controllerv1alpha1.HoistNotifier, mutate func(*controllerv1alpha1.HoistNotifierStatus)) error {
+	first := true
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if !first {
+			if err := n.Client.Get(ctx, client.ObjectKeyFromObject(notifier), notifier); err != nil {
+				return err
+			}
+		}
+		first = false
+		base := notifier.DeepCopy()
+		mutate(&notifier.Status)
+		return n.Client.Status().Patch(ctx, notifier, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{}))
+	})
+}
+
+// Render returns the body of a notification. Without a template the notification itself is sent as JSON, otherwise
+// the template has to render valid JSON
+func Render(payloadTemplate string, notification Notification) ([]byte, error) {
+	if payloadTemplate == "" {
+		return json.Marshal(notification)
+	}
+	tmpl, err := template.New("payload").Option("missingkey=error").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			out, err := json.Marshal(v)
+			return string(out), err
+		},
+	}).Parse(payloadTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload template: %w", err)
+	}
+	out := &bytes.Buffer{}
+	if err := tmpl.Execute(out, notification); err != nil {
+		return nil, fmt.Errorf("failed to render payload template: %w", err)
+	}
+	if !json.Valid(out.Bytes()) {
+		return out.Bytes(), errors.New("payload template did not render valid JSON")
+	}
+	return out.Bytes(), nil
+}
+
+// Sign returns the signature of a payload which is sent in the SignatureHeader
+func Sign(key, payload []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
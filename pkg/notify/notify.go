@@ -0,0 +1,238 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups=controller.kubehoist.io,resources=hoistnotifiers,verbs=get;list;watch
+// +kubebuilder:rbac:groups=controller.kubehoist.io,resources=hoistnotifiers/status,verbs=get;update;patch
+
+// Notification is sent when the controller of a ControllerWatch transitions to another installation status
+type Notification struct {
+	// Event is the installation status which the controller transitioned into
+	Event controllerv1alpha1.ControllerInstallationStatus `json:"event"`
+	// Previous is the installation status which the controller transitioned from
+	Previous  controllerv1alpha1.ControllerInstallationStatus `json:"previous,omitempty"`
+	Kind      string                                          `json:"kind"`
+	Namespace string                                          `json:"namespace,omitempty"`
+	Name      string                                          `json:"name"`
+	Chart     string                                          `json:"chart"`
+	Release   string                                          `json:"release"`
+	// Message is the error of a failed installation
+	Message string `json:"message,omitempty"`
+	// Trigger is the custom resource which last triggered the installation
+	Trigger *controllerv1alpha1.TriggerSource `json:"trigger,omitempty"`
+	Time    time.Time                         `json:"time"`
+}
+
+// ControllerWatch returns the kind/namespace/name of the ControllerWatch which the notification is about
+func (n Notification) ControllerWatch() string {
+	if n.Namespace != "" {
+		return n.Kind + "/" + n.Namespace + "/" + n.Name
+	}
+	return n.Kind + "/" + n.Name
+}
+
+// errQueueFull is the error of a notification which was dropped because too many are waiting for the HoistNotifier
+var errQueueFull = errors.New("too many notifications are queued for delivery")
+
+// Notifier watches the installation status of ControllerWatches, and sends notifications of their transitions to
+// the HoistNotifiers. It only runs on the leader, which is the one writing the transitions
+type Notifier struct {
+	// Client lists the HoistNotifiers and writes their status
+	Client client.Client
+	// Reader reads the signing keys, so that secrets don't have to be cached
+	Reader client.Reader
+	// Namespace is the namespace of kubehoist, which the signing keys are read from
+	Namespace string
+	// Informers are watched for transitions of the installation status of ControllerWatches
+	Informers  cache.Informers
+	HTTPClient *http.Client
+	// Backoff is the delay between attempts to deliver a notification
+	Backoff wait.Backoff
+	// QueueLength is how many notifications can wait for delivery to a single HoistNotifier. More are dead lettered
+	QueueLength int
+
+	lock sync.Mutex
+	// queues hold the notifications of each HoistNotifier, so that a webhook which is down doesn't delay the others
+	queues map[string]chan Notification
+}
+
+// New creates a Notifier which is started by adding it to the manager
+func New(c client.Client, reader client.Reader, informers cache.Informers, namespace string) *Notifier {
+	return &Notifier{
+		Client:      c,
+		Reader:      reader,
+		Namespace:   namespace,
+		Informers:   informers,
+		HTTPClient:  &http.Client{Timeout: 10 * time.Second},
+		Backoff:     wait.Backoff{Duration: time.Second, Factor: 2, Jitter: 0.1, Steps: 5},
+		QueueLength: 100,
+		queues:      map[string]chan Notification{},
+	}
+}
+
+// Start watches both kinds of ControllerWatches and sends the notifications until the context is cancelled
+func (n *Notifier) Start(ctx context.Context) error {
+	ctx = log.IntoContext(ctx, log.FromContext(ctx).WithName("notify"))
+	for _, obj := range []client.Object{&controllerv1alpha1.ControllerWatch{}, &controllerv1alpha1.NamespacedControllerWatch{}} {
+		informer, err := n.Informers.GetInformer(ctx, obj)
+		if err != nil {
+			return err
+		}
+		// Objects which already exist are added to the handler, but only updates can be transitions
+		if _, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(oldObj, newObj interface{}) { n.transition(ctx, oldObj, newObj) },
+		}); err != nil {
+			return err
+		}
+	}
+	<-ctx.Done()
+	return nil
+}
+
+// transition notifies the HoistNotifiers if the installation status of a ControllerWatch changed
+func (n *Notifier) transition(ctx context.Context, oldObj, newObj interface{}) {
+	oldControllerWatch, ok := oldObj.(controllerv1alpha1.ControllerWatchObject)
+	if !ok {
+		return
+	}
+	newControllerWatch, ok := newObj.(controllerv1alpha1.ControllerWatchObject)
+	if !ok {
+		return
+	}
+	notification, ok := newNotification(oldControllerWatch, newControllerWatch)
+	if !ok {
+		return
+	}
+	n.notify(ctx, notification)
+}
+
+// notify queues a notification for every HoistNotifier which wants it
+func (n *Notifier) notify(ctx context.Context, notification Notification) {
+	notifiers := &controllerv1alpha1.HoistNotifierList{}
+	if err := n.Client.List(ctx, notifiers); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list HoistNotifiers", "ControllerWatch", notification.ControllerWatch(), "event", notification.Event)
+		return
+	}
+	for i := range notifiers.Items {
+		if wants(&notifiers.Items[i], notification.Event) {
+			n.enqueue(ctx, &notifiers.Items[i], notification)
+		}
+	}
+}
+
+// enqueue queues a notification for a HoistNotifier, starting a worker which delivers its notifications one at a time
+// if there is none. A notification which doesn't fit in the queue is dead lettered instead of blocking the informer
+func (n *Notifier) enqueue(ctx context.Context, notifier *controllerv1alpha1.HoistNotifier, notification Notification) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	queue, ok := n.queues[notifier.Name]
+	if !ok {
+		queue = make(chan Notification, n.QueueLength)
+		n.queues[notifier.Name] = queue
+		go n.run(ctx, notifier.Name, queue)
+	}
+	select {
+	case queue <- notification:
+	default:
+		go func() {
+			payload, _ := Render(notifier.Spec.PayloadTemplate, notification)
+			if err := n.deadLetter(ctx, notifier, notification, payload, 0, errQueueFull); err != nil && !errors.Is(err, context.Canceled) {
+				log.FromContext(ctx).Error(err, "Failed to record dropped notification", "HoistNotifier", notifier.Name,
+					"ControllerWatch", notification.ControllerWatch(), "event", notification.Event)
+			}
+		}()
+	}
+}
+
+// run delivers the queued notifications of a HoistNotifier until its queue is empty or the context is cancelled
+func (n *Notifier) run(ctx context.Context, name string, queue chan Notification) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case notification := <-queue:
+			// The latest spec is delivered to, and nothing if the HoistNotifier was deleted
+			notifier := &controllerv1alpha1.HoistNotifier{}
+			err := n.Client.Get(ctx, client.ObjectKey{Name: name}, notifier)
+			if err == nil {
+				err = n.deliver(ctx, notifier, notification)
+			}
+			if err != nil && !errors.Is(err, context.Canceled) && !apierrors.IsNotFound(err) {
+				log.FromContext(ctx).Error(err, "Failed to send notification", "HoistNotifier", name,
+					"ControllerWatch", notification.ControllerWatch(), "event", notification.Event)
+			}
+		}
+		n.lock.Lock()
+		if len(queue) == 0 {
+			delete(n.queues, name)
+			n.lock.Unlock()
+			return
+		}
+		n.lock.Unlock()
+	}
+}
+
+// newNotification returns the notification of the transition between two versions of a ControllerWatch, if its
+// installation status changed
+func newNotification(oldControllerWatch, newControllerWatch controllerv1alpha1.ControllerWatchObject) (Notification, bool) {
+	previous := oldControllerWatch.GetStatus().ControllerInstallationStatus
+	status := newControllerWatch.GetStatus()
+	if status.ControllerInstallationStatus == "" || status.ControllerInstallationStatus == previous {
+		return Notification{}, false
+	}
+	kind := "ControllerWatch"
+	if newControllerWatch.GetNamespace() != "" {
+		kind = "NamespacedControllerWatch"
+	}
+	notification := Notification{
+		Event:     status.ControllerInstallationStatus,
+		Previous:  previous,
+		Kind:      kind,
+		Namespace: newControllerWatch.GetNamespace(),
+		Name:      newControllerWatch.GetName(),
+		Chart:     newControllerWatch.GetSpec().HelmControllerSpec.Chart,
+		Release:   newControllerWatch.GetSpec().HelmControllerSpec.ReleaseName,
+		Trigger:   status.LastTrigger,
+		Time:      time.Now().UTC(),
+	}
+	if status.ControllerInstallationStatus == controllerv1alpha1.ControllerInstallationStatusInstallFailed && status.InstallProgress != nil {
+		notification.Message = status.InstallProgress.Message
+	}
+	return notification, true
+}
+
+func wants(notifier *controllerv1alpha1.HoistNotifier, event controllerv1alpha1.ControllerInstallationStatus) bool {
+	return len(notifier.Spec.Events) == 0 || slices.Contains(notifier.Spec.Events, event)
+}
@@ -0,0 +1,320 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+)
+
+// webhook is a local HTTP server which responds with the given status codes in order, and then with 200
+type webhook struct {
+	server   *httptest.Server
+	lock     sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newWebhook(statuses ...int) *webhook {
+	w := &webhook{statuses: statuses}
+	w.server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		w.lock.Lock()
+		defer w.lock.Unlock()
+		body, _ := io.ReadAll(req.Body)
+		w.requests = append(w.requests, req)
+		w.bodies = append(w.bodies, body)
+		status := http.StatusOK
+		if len(w.statuses) > 0 {
+			status, w.statuses = w.statuses[0], w.statuses[1:]
+		}
+		rw.WriteHeader(status)
+	}))
+	return w
+}
+
+var _ = Describe("Notifier", func() {
+	var kclient client.Client
+	var notifier *Notifier
+	var hook *webhook
+	ctx := context.Background()
+	notification := Notification{
+		Event:    controllerv1alpha1.ControllerInstallationStatusInstalled,
+		Previous: controllerv1alpha1.ControllerInstallationStatusInstalling,
+		Kind:     "ControllerWatch",
+		Name:     "cert-manager",
+		Chart:    "oci://registry-1.docker.io/bitnamicharts/cert-manager",
+		Release:  "certmanager",
+		Time:     time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	setup := func(statuses []int, spec controllerv1alpha1.HoistNotifierSpec) {
+		hook = newWebhook(statuses...)
+		DeferCleanup(hook.server.Close)
+		spec.URL = hook.server.URL
+		if spec.MaxAttempts == 0 {
+			spec.MaxAttempts = 3
+		}
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(controllerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		kclient = fake.NewClientBuilder().WithScheme(scheme).
+			WithStatusSubresource(&controllerv1alpha1.HoistNotifier{}).
+			WithObjects(
+				&controllerv1alpha1.HoistNotifier{ObjectMeta: metav1.ObjectMeta{Name: "chat"}, Spec: spec},
+				&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "kubehoist-system", Name: "chat"}, Data: map[string][]byte{"key": []byte("secret")}},
+			).Build()
+		notifier = New(kclient, kclient, nil, "kubehoist-system")
+		notifier.Backoff = wait.Backoff{Duration: time.Millisecond, Factor: 2, Steps: 5}
+	}
+
+	// send queues the notification and waits until the queues are drained
+	send := func(notification Notification) {
+		notifier.notify(ctx, notification)
+		Eventually(func() int {
+			notifier.lock.Lock()
+			defer notifier.lock.Unlock()
+			return len(notifier.queues)
+		}).Should(BeZero())
+	}
+
+	status := func() controllerv1alpha1.HoistNotifierStatus {
+		hoistNotifier := &controllerv1alpha1.HoistNotifier{}
+		Expect(kclient.Get(ctx, client.ObjectKey{Name: "chat"}, hoistNotifier)).To(Succeed())
+		return hoistNotifier.Status
+	}
+
+	It("should POST the notification as JSON and sign it", func() {
+		setup(nil, controllerv1alpha1.HoistNotifierSpec{
+			SigningKeySecretRef: &controllerv1alpha1.SecretKeyReference{Name: "chat", Key: "key"},
+		})
+		send(notification)
+
+		Expect(hook.requests).To(HaveLen(1))
+		Expect(hook.requests[0].Header.Get("Content-Type")).To(Equal("application/json"))
+		Expect(hook.requests[0].Header.Get(EventHeader)).To(Equal("Installed"))
+		Expect(hook.requests[0].Header.Get(SignatureHeader)).To(Equal(Sign([]byte("secret"), hook.bodies[0])))
+		sent := Notification{}
+		Expect(json.Unmarshal(hook.bodies[0], &sent)).To(Succeed())
+		Expect(sent).To(Equal(notification))
+		Expect(status().Delivered).To(Equal(int64(1)))
+		Expect(status().LastDelivered).NotTo(BeNil())
+	})
+
+	It("should only read signing keys from the namespace of kubehoist", func() {
+		setup(nil, controllerv1alpha1.HoistNotifierSpec{
+			SigningKeySecretRef: &controllerv1alpha1.SecretKeyReference{Name: "chat", Key: "key"},
+		})
+		notifier.Namespace = "team"
+		send(notification)
+
+		Expect(hook.requests).To(BeEmpty())
+		Expect(status().DeadLetters).To(HaveLen(1))
+		Expect(status().DeadLetters[0].Error).To(ContainSubstring("failed to get signing key"))
+	})
+
+	It("should render the payload template", func() {
+		setup(nil, controllerv1alpha1.HoistNotifierSpec{
+			PayloadTemplate: `{"text": {{ printf "%s %s is %s" .Kind .Name .Event | json }}}`,
+		})
+		send(notification)
+
+		Expect(hook.bodies).To(HaveLen(1))
+		Expect(string(hook.bodies[0])).To(Equal(`{"text": "ControllerWatch cert-manager is Installed"}`))
+	})
+
+	It("should only send the events of the filter", func() {
+		setup(nil, controllerv1alpha1.HoistNotifierSpec{
+			Events: []controllerv1alpha1.ControllerInstallationStatus{controllerv1alpha1.ControllerInstallationStatusInstallFailed},
+		})
+		send(notification)
+		Expect(hook.requests).To(BeEmpty())
+	})
+
+	It("should retry server errors with the same delivery id", func() {
+		setup([]int{http.StatusInternalServerError, http.StatusTooManyRequests}, controllerv1alpha1.HoistNotifierSpec{})
+		send(notification)
+
+		Expect(hook.requests).To(HaveLen(3))
+		Expect(hook.requests[2].Header.Get(DeliveryHeader)).To(Equal(hook.requests[0].Header.Get(DeliveryHeader)))
+		Expect(status().Delivered).To(Equal(int64(1)))
+		Expect(status().DeadLetters).To(BeEmpty())
+	})
+
+	It("should record a dead letter once the attempts are exhausted", func() {
+		setup([]int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}, controllerv1alpha1.HoistNotifierSpec{})
+		send(notification)
+
+		Expect(hook.requests).To(HaveLen(3))
+		Expect(status().Failed).To(Equal(int64(1)))
+		Expect(status().DeadLetters).To(HaveLen(1))
+		deadLetter := status().DeadLetters[0]
+		Expect(deadLetter.Event).To(Equal(controllerv1alpha1.ControllerInstallationStatusInstalled))
+		Expect(deadLetter.ControllerWatch).To(Equal("ControllerWatch/cert-manager"))
+		Expect(deadLetter.Attempts).To(Equal(int32(3)))
+		Expect(deadLetter.Error).To(ContainSubstring("502"))
+		Expect(deadLetter.Payload).To(Equal(string(hook.bodies[0])))
+	})
+
+	It("should not retry client errors", func() {
+		setup([]int{http.StatusNotFound}, controllerv1alpha1.HoistNotifierSpec{})
+		send(notification)
+
+		Expect(hook.requests).To(HaveLen(1))
+		Expect(status().DeadLetters).To(HaveLen(1))
+		Expect(status().DeadLetters[0].Attempts).To(Equal(int32(1)))
+	})
+
+	It("should record a dead letter without sending if the payload template is invalid", func() {
+		setup(nil, controllerv1alpha1.HoistNotifierSpec{PayloadTemplate: `{"text": {{ .Name }}}`})
+		send(notification)
+
+		Expect(hook.requests).To(BeEmpty())
+		Expect(status().DeadLetters).To(HaveLen(1))
+		Expect(status().DeadLetters[0].Error).To(ContainSubstring("valid JSON"))
+	})
+
+	It("should keep only the most recent dead letters", func() {
+		statuses := []int{}
+		for range controllerv1alpha1.MaxDeadLetters + 2 {
+			statuses = append(statuses, http.StatusBadRequest)
+		}
+		setup(statuses, controllerv1alpha1.HoistNotifierSpec{})
+		for i := range controllerv1alpha1.MaxDeadLetters + 2 {
+			notification := notification
+			notification.Name = string(rune('a' + i))
+			send(notification)
+		}
+
+		Expect(status().Failed).To(Equal(int64(controllerv1alpha1.MaxDeadLetters + 2)))
+		Expect(status().DeadLetters).To(HaveLen(controllerv1alpha1.MaxDeadLetters))
+		Expect(status().DeadLetters[0].ControllerWatch).To(Equal("ControllerWatch/c"))
+	})
+})
+
+var _ = Describe("newNotification", func() {
+	controllerWatch := func(status controllerv1alpha1.ControllerInstallationStatus) *controllerv1alpha1.NamespacedControllerWatch {
+		controllerWatch := &controllerv1alpha1.NamespacedControllerWatch{ObjectMeta: metav1.ObjectMeta{Namespace: "team", Name: "cert-manager"}}
+		controllerWatch.Spec.HelmControllerSpec.ReleaseName = "certmanager"
+		controllerWatch.Status.ControllerInstallationStatus = status
+		controllerWatch.Status.InstallProgress = &controllerv1alpha1.InstallProgress{Message: "timed out"}
+		return controllerWatch
+	}
+
+	It("should ignore updates which don't change the installation status", func() {
+		_, ok := newNotification(controllerWatch(controllerv1alpha1.ControllerInstallationStatusInstalled), controllerWatch(controllerv1alpha1.ControllerInstallationStatusInstalled))
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should describe a failed install", func() {
+		notification, ok := newNotification(controllerWatch(controllerv1alpha1.ControllerInstallationStatusInstalling), controllerWatch(controllerv1alpha1.ControllerInstallationStatusInstallFailed))
+		Expect(ok).To(BeTrue())
+		Expect(notification.Event).To(Equal(controllerv1alpha1.ControllerInstallationStatusInstallFailed))
+		Expect(notification.Previous).To(Equal(controllerv1alpha1.ControllerInstallationStatusInstalling))
+		Expect(notification.ControllerWatch()).To(Equal("NamespacedControllerWatch/team/cert-manager"))
+		Expect(notification.Release).To(Equal("certmanager"))
+		Expect(notification.Message).To(Equal("timed out"))
+	})
+})
+
+var _ = Describe("transition", func() {
+	var kclient client.Client
+	var notifier *Notifier
+	var fast *webhook
+	// release unblocks the requests to the slow webhook
+	var release chan struct{}
+	var slowRequests atomic.Int32
+
+	transition := func(ctx context.Context, name string) {
+		oldControllerWatch := &controllerv1alpha1.ControllerWatch{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		oldControllerWatch.Status.ControllerInstallationStatus = controllerv1alpha1.ControllerInstallationStatusInstalling
+		newControllerWatch := oldControllerWatch.DeepCopy()
+		newControllerWatch.Status.ControllerInstallationStatus = controllerv1alpha1.ControllerInstallationStatusInstalled
+		notifier.transition(ctx, oldControllerWatch, newControllerWatch)
+	}
+
+	status := func(name string) controllerv1alpha1.HoistNotifierStatus {
+		hoistNotifier := &controllerv1alpha1.HoistNotifier{}
+		Expect(kclient.Get(context.Background(), client.ObjectKey{Name: name}, hoistNotifier)).To(Succeed())
+		return hoistNotifier.Status
+	}
+
+	BeforeEach(func() {
+		fast = newWebhook()
+		DeferCleanup(fast.server.Close)
+		release = make(chan struct{})
+		slowRequests.Store(0)
+		slow := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			slowRequests.Add(1)
+			<-release
+		}))
+		DeferCleanup(slow.Close)
+		DeferCleanup(func() { close(release) })
+
+		scheme := runtime.NewScheme()
+		Expect(controllerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		kclient = fake.NewClientBuilder().WithScheme(scheme).
+			WithStatusSubresource(&controllerv1alpha1.HoistNotifier{}).
+			WithObjects(
+				&controllerv1alpha1.HoistNotifier{ObjectMeta: metav1.ObjectMeta{Name: "slow"}, Spec: controllerv1alpha1.HoistNotifierSpec{URL: slow.URL}},
+				&controllerv1alpha1.HoistNotifier{ObjectMeta: metav1.ObjectMeta{Name: "fast"}, Spec: controllerv1alpha1.HoistNotifierSpec{URL: fast.server.URL}},
+			).Build()
+		notifier = New(kclient, kclient, nil, "kubehoist-system")
+	})
+
+	It("should not delay a HoistNotifier behind a slow one", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		transition(ctx, "a")
+		transition(ctx, "b")
+		Eventually(func() int64 { return status("fast").Delivered }).Should(Equal(int64(2)))
+		Expect(slowRequests.Load()).To(Equal(int32(1)))
+	})
+
+	It("should dead letter notifications which don't fit in the queue", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		DeferCleanup(cancel)
+		notifier.QueueLength = 1
+		transition(ctx, "a")
+		Eventually(slowRequests.Load).Should(Equal(int32(1)))
+		// b waits in the queue, and c doesn't fit anymore
+		transition(ctx, "b")
+		transition(ctx, "c")
+		Eventually(func() int64 { return status("slow").Failed }).Should(Equal(int64(1)))
+		deadLetter := status("slow").DeadLetters[0]
+		Expect(deadLetter.ControllerWatch).To(Equal("ControllerWatch/c"))
+		Expect(deadLetter.Error).To(Equal(errQueueFull.Error()))
+	})
+})
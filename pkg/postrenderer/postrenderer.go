@@ -0,0 +1,239 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postrenderer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"helm.sh/helm/v3/pkg/postrender"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/helm"
+)
+
+// PostRenderer applies the patches of a PostRendererSpec to the rendered manifests of a chart
+type PostRenderer struct {
+	namespace             string
+	strategicMergePatches []patch
+	json6902Patches       []patch
+	imageRewrites         []controllerv1alpha1.ImageRewrite
+}
+
+var _ postrender.PostRenderer = &PostRenderer{}
+
+type patch struct {
+	target   controllerv1alpha1.PatchTarget
+	selector labels.Selector
+	// data is the json encoded strategic merge patch
+	data []byte
+	// operations is the decoded JSON 6902 patch
+	operations jsonpatch.Patch
+}
+
+// New validates a PostRendererSpec and creates a PostRenderer for it.
+// namespace is the release namespace, which rendered resources without a namespace are installed into
+func New(namespace string, spec *controllerv1alpha1.PostRendererSpec) (*PostRenderer, error) {
+	p := &PostRenderer{namespace: namespace, imageRewrites: spec.ImageRewrites}
+	for i, manifestPatch := range spec.StrategicMergePatches {
+		parsed, err := newPatch(manifestPatch)
+		if err != nil {
+			return nil, fmt.Errorf("invalid strategic merge patch %d: %w", i, err)
+		}
+		p.strategicMergePatches = append(p.strategicMergePatches, parsed)
+	}
+	for i, manifestPatch := range spec.JSON6902Patches {
+		parsed, err := newPatch(manifestPatch)
+		if err != nil {
+			return nil, fmt.Errorf("invalid json6902 patch %d: %w", i, err)
+		}
+		parsed.operations, err = jsonpatch.DecodePatch(parsed.data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid json6902 patch %d: %w", i, err)
+		}
+		p.json6902Patches = append(p.json6902Patches, parsed)
+	}
+	for i, rewrite := range spec.ImageRewrites {
+		if rewrite.From == "" {
+			return nil, fmt.Errorf("image rewrite %d has an empty from", i)
+		}
+	}
+	return p, nil
+}
+
+func newPatch(manifestPatch controllerv1alpha1.ManifestPatch) (patch, error) {
+	selector, err := labels.Parse(manifestPatch.Target.LabelSelector)
+	if err != nil {
+		return patch{}, fmt.Errorf("invalid label selector: %w", err)
+	}
+	data, err := yaml.YAMLToJSON([]byte(manifestPatch.Patch))
+	if err != nil {
+		return patch{}, err
+	}
+	return patch{target: manifestPatch.Target, selector: selector, data: data}, nil
+}
+
+// Run patches every resource in the rendered manifests
+func (p *PostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	resources, err := helm.ExtractResources(renderedManifests.String())
+	if err != nil {
+		return nil, err
+	}
+	out := &bytes.Buffer{}
+	for _, resource := range resources {
+		patched, err := p.patchResource(resource)
+		if err != nil {
+			return nil, fmt.Errorf("failed to patch %s %s: %w", resource.GetKind(), resource.GetName(), err)
+		}
+		data, err := yaml.Marshal(patched.Object)
+		if err != nil {
+			return nil, err
+		}
+		out.WriteString("---\n")
+		out.Write(data)
+	}
+	return out, nil
+}
+
+func (p *PostRenderer) patchResource(resource *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	p.rewriteImages(resource.Object)
+	for _, smp := range p.strategicMergePatches {
+		if !p.matches(smp, resource) {
+			continue
+		}
+		original, err := json.Marshal(resource.Object)
+		if err != nil {
+			return nil, err
+		}
+		var patched []byte
+		if dataStruct, err := clientgoscheme.Scheme.New(resource.GroupVersionKind()); err == nil {
+			patched, err = strategicpatch.StrategicMergePatch(original, smp.data, dataStruct)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			// There is no strategic merge schema for kinds which are not built in
+			patched, err = jsonpatch.MergePatch(original, smp.data)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if resource, err = decode(patched); err != nil {
+			return nil, err
+		}
+	}
+	for _, operations := range p.json6902Patches {
+		if !p.matches(operations, resource) {
+			continue
+		}
+		original, err := json.Marshal(resource.Object)
+		if err != nil {
+			return nil, err
+		}
+		patched, err := operations.operations.Apply(original)
+		if err != nil {
+			return nil, err
+		}
+		if resource, err = decode(patched); err != nil {
+			return nil, err
+		}
+	}
+	return resource, nil
+}
+
+func decode(data []byte) (*unstructured.Unstructured, error) {
+	resource := &unstructured.Unstructured{}
+	if err := resource.UnmarshalJSON(data); err != nil {
+		return nil, err
+	}
+	return resource, nil
+}
+
+func (p *PostRenderer) matches(manifestPatch patch, resource *unstructured.Unstructured) bool {
+	target := manifestPatch.target
+	gvk := resource.GroupVersionKind()
+	namespace := resource.GetNamespace()
+	if namespace == "" {
+		namespace = p.namespace
+	}
+	return (target.Group == "" || target.Group == gvk.Group) &&
+		(target.Version == "" || target.Version == gvk.Version) &&
+		(target.Kind == "" || target.Kind == gvk.Kind) &&
+		(target.Namespace == "" || target.Namespace == namespace) &&
+		(target.Name == "" || target.Name == resource.GetName()) &&
+		manifestPatch.selector.Matches(labels.Set(resource.GetLabels()))
+}
+
+// rewriteImages rewrites the image of every container list found anywhere in the object, so that pod templates of
+// any workload kind are covered
+func (p *PostRenderer) rewriteImages(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if key == "containers" || key == "initContainers" || key == "ephemeralContainers" {
+				if containers, ok := child.([]interface{}); ok {
+					for _, container := range containers {
+						if container, ok := container.(map[string]interface{}); ok {
+							if image, ok := container["image"].(string); ok {
+								container["image"] = p.rewriteImage(image)
+							}
+						}
+					}
+				}
+			}
+			p.rewriteImages(child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			p.rewriteImages(child)
+		}
+	}
+}
+
+// rewriteImage applies the first matching image rewrite. Images are also matched with their implicit docker.io
+// registry, so that nginx matches a rewrite from docker.io/library
+func (p *PostRenderer) rewriteImage(image string) string {
+	for _, rewrite := range p.imageRewrites {
+		for _, candidate := range []string{image, normalizeImage(image)} {
+			rest, ok := strings.CutPrefix(candidate, rewrite.From)
+			// Only match whole path components, so that docker.io/bitnami doesn't match docker.io/bitnamilegacy
+			if ok && (rest == "" || strings.HasSuffix(rewrite.From, "/") || strings.ContainsAny(rest[:1], "/:@")) {
+				return rewrite.To + rest
+			}
+		}
+	}
+	return image
+}
+
+func normalizeImage(image string) string {
+	first, _, hasSlash := strings.Cut(image, "/")
+	if hasSlash && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return image
+	}
+	if !hasSlash {
+		return "docker.io/library/" + image
+	}
+	return "docker.io/" + image
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postrenderer
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/helm"
+)
+
+const manifest = `---
+# Source: example/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: controller
+  labels:
+    app: controller
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: busybox:1.36
+      containers:
+      - name: controller
+        image: docker.io/bitnami/controller:1.0.0
+      tolerations:
+      - key: existing
+        operator: Exists
+---
+# Source: example/templates/widget.yaml
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: default
+spec:
+  replicas: 1
+`
+
+func render(spec *controllerv1alpha1.PostRendererSpec) map[string]*unstructured.Unstructured {
+	p, err := New("release-ns", spec)
+	Expect(err).NotTo(HaveOccurred())
+	out, err := p.Run(bytes.NewBufferString(manifest))
+	Expect(err).NotTo(HaveOccurred())
+	resources, err := helm.ExtractResources(out.String())
+	Expect(err).NotTo(HaveOccurred())
+	byKind := map[string]*unstructured.Unstructured{}
+	for _, resource := range resources {
+		byKind[resource.GetKind()] = resource
+	}
+	return byKind
+}
+
+var _ = Describe("PostRenderer", func() {
+	It("merges strategic merge patches into built in kinds", func() {
+		resources := render(&controllerv1alpha1.PostRendererSpec{
+			StrategicMergePatches: []controllerv1alpha1.ManifestPatch{{
+				Target: controllerv1alpha1.PatchTarget{Kind: "Deployment", Namespace: "release-ns", LabelSelector: "app=controller"},
+				Patch: `
+spec:
+  template:
+    spec:
+      nodeSelector:
+        pool: system
+      priorityClassName: system-cluster-critical
+      tolerations:
+      - key: dedicated
+        operator: Exists
+      containers:
+      - name: controller
+        resources:
+          limits:
+            memory: 128Mi
+`,
+			}},
+		})
+		podSpec, _, _ := unstructured.NestedMap(resources["Deployment"].Object, "spec", "template", "spec")
+		Expect(podSpec["nodeSelector"]).To(Equal(map[string]interface{}{"pool": "system"}))
+		Expect(podSpec["priorityClassName"]).To(Equal("system-cluster-critical"))
+		// Tolerations have no merge key, so they are replaced
+		Expect(podSpec["tolerations"]).To(HaveLen(1))
+		// Containers are merged by name
+		Expect(podSpec["containers"]).To(HaveLen(1))
+		Expect(podSpec["containers"].([]interface{})[0]).To(HaveKeyWithValue("image", "docker.io/bitnami/controller:1.0.0"))
+		Expect(podSpec["containers"].([]interface{})[0]).To(HaveKey("resources"))
+		Expect(resources["Widget"].Object).NotTo(HaveKey("nodeSelector"))
+	})
+
+	It("applies JSON 6902 patches to custom resources", func() {
+		resources := render(&controllerv1alpha1.PostRendererSpec{
+			JSON6902Patches: []controllerv1alpha1.ManifestPatch{{
+				Target: controllerv1alpha1.PatchTarget{Group: "example.com", Kind: "Widget"},
+				Patch:  `[{"op": "replace", "path": "/spec/replicas", "value": 3}]`,
+			}},
+		})
+		replicas, _, _ := unstructured.NestedInt64(resources["Widget"].Object, "spec", "replicas")
+		Expect(replicas).To(Equal(int64(3)))
+	})
+
+	It("skips patches for other targets", func() {
+		resources := render(&controllerv1alpha1.PostRendererSpec{
+			JSON6902Patches: []controllerv1alpha1.ManifestPatch{{
+				Target: controllerv1alpha1.PatchTarget{Kind: "Widget", Namespace: "other-ns"},
+				Patch:  `[{"op": "replace", "path": "/spec/replicas", "value": 3}]`,
+			}},
+		})
+		replicas, _, _ := unstructured.NestedInt64(resources["Widget"].Object, "spec", "replicas")
+		Expect(replicas).To(Equal(int64(1)))
+	})
+
+	It("rewrites container images", func() {
+		resources := render(&controllerv1alpha1.PostRendererSpec{
+			ImageRewrites: []controllerv1alpha1.ImageRewrite{
+				{From: "docker.io/bitnami", To: "mirror.example.com/bitnami"},
+				{From: "docker.io/library/", To: "mirror.example.com/library/"},
+			},
+		})
+		podSpec, _, _ := unstructured.NestedMap(resources["Deployment"].Object, "spec", "template", "spec")
+		Expect(podSpec["containers"].([]interface{})[0]).To(HaveKeyWithValue("image", "mirror.example.com/bitnami/controller:1.0.0"))
+		Expect(podSpec["initContainers"].([]interface{})[0]).To(HaveKeyWithValue("image", "mirror.example.com/library/busybox:1.36"))
+	})
+
+	It("only rewrites whole path components", func() {
+		p, err := New("", &controllerv1alpha1.PostRendererSpec{
+			ImageRewrites: []controllerv1alpha1.ImageRewrite{{From: "docker.io/bitnami", To: "mirror.example.com/bitnami"}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.rewriteImage("bitnamilegacy/controller:1.0.0")).To(Equal("bitnamilegacy/controller:1.0.0"))
+		Expect(p.rewriteImage("bitnami/controller:1.0.0")).To(Equal("mirror.example.com/bitnami/controller:1.0.0"))
+	})
+
+	It("rejects invalid patches", func() {
+		_, err := New("", &controllerv1alpha1.PostRendererSpec{
+			JSON6902Patches: []controllerv1alpha1.ManifestPatch{{Patch: `{"op": "replace"}`}},
+		})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,174 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+)
+
+type State string
+
+const (
+	// StateNone means no window is currently active, and the controller is installed based on custom resource usage
+	StateNone State = ""
+	// StateWarm means a warm window is active and the controller should be installed
+	StateWarm State = "Warm"
+	// StateHibernate means a hibernate window is active and the controller should be uninstalled
+	StateHibernate State = "Hibernate"
+)
+
+// maxLookback is how far back LastWarmWindowEnd looks for the end of a warm window
+const maxLookback = 7 * 24 * time.Hour
+
+// Evaluate returns which kind of window is active at the given time, and when the next window boundary is,
+// which is when the state should be evaluated again
+func Evaluate(spec *controllerv1alpha1.ScheduleSpec, now time.Time) (State, time.Time, error) {
+	if spec == nil {
+		return StateNone, time.Time{}, nil
+	}
+	location, err := timeZone(spec)
+	if err != nil {
+		return StateNone, time.Time{}, err
+	}
+	now = now.In(location)
+
+	warm, nextBoundary, err := evaluateWindows(spec.WarmWindows, now, time.Time{})
+	if err != nil {
+		return StateNone, time.Time{}, err
+	}
+	hibernate, nextBoundary, err := evaluateWindows(spec.HibernateWindows, now, nextBoundary)
+	if err != nil {
+		return StateNone, time.Time{}, err
+	}
+
+	switch {
+	case hibernate:
+		return StateHibernate, nextBoundary, nil
+	case warm:
+		return StateWarm, nextBoundary, nil
+	default:
+		return StateNone, nextBoundary, nil
+	}
+}
+
+// LastWarmWindowEnd returns when the most recent warm window which is not active anymore ended, or the zero time if
+// none ended within the last week. A warm window which started again before the previous one ended only ends once
+func LastWarmWindowEnd(spec *controllerv1alpha1.ScheduleSpec, now time.Time) (time.Time, error) {
+	if spec == nil {
+		return time.Time{}, nil
+	}
+	location, err := timeZone(spec)
+	if err != nil {
+		return time.Time{}, err
+	}
+	now = now.In(location)
+	warm, _, err := evaluateWindows(spec.WarmWindows, now, time.Time{})
+	if err != nil || warm {
+		return time.Time{}, err
+	}
+	lastEnd := time.Time{}
+	for _, window := range spec.WarmWindows {
+		schedule, err := cron.ParseStandard(window.Start)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid window start %q: %w", window.Start, err)
+		}
+		// The latest start of this window which already ended
+		start := previous(schedule, now.Add(-window.Duration.Duration), maxLookback)
+		if end := start.Add(window.Duration.Duration); !start.IsZero() && end.After(lastEnd) {
+			lastEnd = end
+		}
+	}
+	return lastEnd, nil
+}
+
+func timeZone(spec *controllerv1alpha1.ScheduleSpec) (*time.Location, error) {
+	if spec.TimeZone == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(spec.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %w", spec.TimeZone, err)
+	}
+	return location, nil
+}
+
+// evaluateWindows returns if any of the windows are active at the given time, and the earliest of nextBoundary and
+// the next boundary of any of these windows
+func evaluateWindows(windows []controllerv1alpha1.ScheduleWindow, now time.Time, nextBoundary time.Time) (bool, time.Time, error) {
+	active := false
+	for _, window := range windows {
+		schedule, err := cron.ParseStandard(window.Start)
+		if err != nil {
+			return false, time.Time{}, fmt.Errorf("invalid window start %q: %w", window.Start, err)
+		}
+		boundary := schedule.Next(now)
+		// Only the latest start within the duration of the window can still be active
+		if start := previous(schedule, now, window.Duration.Duration); !start.IsZero() {
+			if end := start.Add(window.Duration.Duration); end.After(now) {
+				active = true
+				boundary = end
+			}
+		}
+		if !boundary.IsZero() && (nextBoundary.IsZero() || boundary.Before(nextBoundary)) {
+			nextBoundary = boundary
+		}
+	}
+	return active, nextBoundary, nil
+}
+
+// previous returns the latest activation of the schedule at or before t, or the zero time if there is none within
+// lookback. It searches backwards in doubling steps and then bisects, so the number of calls to Next only grows with
+// the logarithm of the lookback, no matter how often the schedule activates
+func previous(schedule cron.Schedule, t time.Time, lookback time.Duration) time.Time {
+	// Cron schedules have a resolution of one second
+	step := time.Second
+	for {
+		if step > lookback {
+			step = lookback
+		}
+		if next := schedule.Next(t.Add(-step)); !next.After(t) {
+			return latestActivation(schedule, next, t)
+		}
+		if step == lookback {
+			return time.Time{}
+		}
+		step *= 2
+	}
+}
+
+// latestActivation returns the latest activation of the schedule at or before t, given an activation lo before t
+func latestActivation(schedule cron.Schedule, lo, t time.Time) time.Time {
+	// The latest activation is always between lo and hi
+	hi := t
+	for hi.Sub(lo) > time.Second {
+		mid := lo.Add(hi.Sub(lo) / 2)
+		if next := schedule.Next(mid); !next.After(t) {
+			lo = next
+		} else {
+			hi = mid
+		}
+	}
+	if next := schedule.Next(lo); !next.After(hi) {
+		return next
+	}
+	return lo
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package schedule
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/robfig/cron/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+)
+
+var _ = Describe("Evaluate", func() {
+	// Warm from 08:00 to 18:00 on weekdays, hibernate from 22:00 to 06:00 every day
+	spec := &controllerv1alpha1.ScheduleSpec{
+		TimeZone: "Europe/Berlin",
+		WarmWindows: []controllerv1alpha1.ScheduleWindow{
+			{Start: "0 8 * * 1-5", Duration: metav1.Duration{Duration: 10 * time.Hour}},
+		},
+		HibernateWindows: []controllerv1alpha1.ScheduleWindow{
+			{Start: "0 22 * * *", Duration: metav1.Duration{Duration: 8 * time.Hour}},
+		},
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	It("should return no state without a schedule", func() {
+		state, next, err := Evaluate(nil, time.Now())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state).To(Equal(StateNone))
+		Expect(next.IsZero()).To(BeTrue())
+	})
+
+	It("should be warm during a warm window until it ends", func() {
+		// Wednesday
+		now := time.Date(2025, 3, 5, 9, 30, 0, 0, berlin)
+		state, next, err := Evaluate(spec, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state).To(Equal(StateWarm))
+		Expect(next).To(BeTemporally("==", time.Date(2025, 3, 5, 18, 0, 0, 0, berlin)))
+	})
+
+	It("should evaluate windows in the configured time zone", func() {
+		// 09:30 in Berlin is 08:30 UTC
+		now := time.Date(2025, 3, 5, 8, 30, 0, 0, time.UTC)
+		state, _, err := Evaluate(spec, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state).To(Equal(StateWarm))
+	})
+
+	It("should have no state between windows", func() {
+		now := time.Date(2025, 3, 5, 19, 0, 0, 0, berlin)
+		state, next, err := Evaluate(spec, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state).To(Equal(StateNone))
+		Expect(next).To(BeTemporally("==", time.Date(2025, 3, 5, 22, 0, 0, 0, berlin)))
+	})
+
+	It("should hibernate during a window which started the previous day", func() {
+		// Saturday, no warm window on the weekend
+		now := time.Date(2025, 3, 8, 3, 0, 0, 0, berlin)
+		state, next, err := Evaluate(spec, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state).To(Equal(StateHibernate))
+		Expect(next).To(BeTemporally("==", time.Date(2025, 3, 8, 6, 0, 0, 0, berlin)))
+	})
+
+	It("should prefer hibernate windows when windows overlap", func() {
+		overlapping := spec.DeepCopy()
+		overlapping.HibernateWindows = append(overlapping.HibernateWindows, controllerv1alpha1.ScheduleWindow{
+			Start: "0 12 * * *", Duration: metav1.Duration{Duration: time.Hour},
+		})
+		now := time.Date(2025, 3, 5, 12, 30, 0, 0, berlin)
+		state, next, err := Evaluate(overlapping, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(state).To(Equal(StateHibernate))
+		Expect(next).To(BeTemporally("==", time.Date(2025, 3, 5, 13, 0, 0, 0, berlin)))
+	})
+
+	It("should return an error for an invalid cron expression", func() {
+		invalid := &controllerv1alpha1.ScheduleSpec{
+			WarmWindows: []controllerv1alpha1.ScheduleWindow{{Start: "every day"}},
+		}
+		_, _, err := Evaluate(invalid, time.Now())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should return an error for an invalid time zone", func() {
+		_, _, err := Evaluate(&controllerv1alpha1.ScheduleSpec{TimeZone: "Mars/Olympus_Mons"}, time.Now())
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("LastWarmWindowEnd", func() {
+	spec := &controllerv1alpha1.ScheduleSpec{
+		TimeZone: "Europe/Berlin",
+		WarmWindows: []controllerv1alpha1.ScheduleWindow{
+			{Start: "0 8 * * 1-5", Duration: metav1.Duration{Duration: 10 * time.Hour}},
+		},
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	It("returns the end of the last warm window", func() {
+		end, err := LastWarmWindowEnd(spec, time.Date(2025, 3, 5, 19, 0, 0, 0, berlin))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(end).To(BeTemporally("==", time.Date(2025, 3, 5, 18, 0, 0, 0, berlin)))
+
+		// Monday morning, the last window was on Friday
+		end, err = LastWarmWindowEnd(spec, time.Date(2025, 3, 10, 7, 0, 0, 0, berlin))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(end).To(BeTemporally("==", time.Date(2025, 3, 7, 18, 0, 0, 0, berlin)))
+	})
+
+	It("returns nothing while a warm window is active", func() {
+		end, err := LastWarmWindowEnd(spec, time.Date(2025, 3, 5, 9, 0, 0, 0, berlin))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(end.IsZero()).To(BeTrue())
+
+		end, err = LastWarmWindowEnd(nil, time.Now())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(end.IsZero()).To(BeTrue())
+	})
+})
+
+// countingSchedule counts how often the next activation of a schedule is computed
+type countingSchedule struct {
+	cron.Schedule
+	calls int
+}
+
+func (c *countingSchedule) Next(t time.Time) time.Time {
+	c.calls++
+	return c.Schedule.Next(t)
+}
+
+var _ = Describe("previous", func() {
+	It("finds the latest activation without stepping through every activation", func() {
+		everyMinute, err := cron.ParseStandard("* * * * *")
+		Expect(err).NotTo(HaveOccurred())
+		schedule := &countingSchedule{Schedule: everyMinute}
+		now := time.Date(2025, 3, 5, 9, 30, 30, 0, time.UTC)
+		Expect(previous(schedule, now, 1000*time.Hour)).To(BeTemporally("==", time.Date(2025, 3, 5, 9, 30, 0, 0, time.UTC)))
+		Expect(schedule.calls).To(BeNumerically("<", 30))
+	})
+
+	It("finds a rare activation far back and nothing beyond the lookback", func() {
+		yearly, err := cron.ParseStandard("0 0 1 1 *")
+		Expect(err).NotTo(HaveOccurred())
+		schedule := &countingSchedule{Schedule: yearly}
+		now := time.Date(2025, 12, 31, 12, 0, 0, 0, time.UTC)
+		Expect(previous(schedule, now, 366*24*time.Hour)).To(BeTemporally("==", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+		Expect(schedule.calls).To(BeNumerically("<", 60))
+		Expect(previous(yearly, now, 24*time.Hour).IsZero()).To(BeTrue())
+	})
+})
@@ -0,0 +1,233 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package statusupdate writes the status of ControllerWatches without losing concurrent changes. Every write is a
+// merge patch of the status subresource, which is retried on a fresh copy of the object when it conflicts
+package statusupdate
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+)
+
+// Mutation changes a status, and returns false if it was already in the wanted state.
+// Mutations are applied again after a conflict, so they may only depend on the status they are given
+type Mutation func(status *controllerv1alpha1.ControllerWatchStatus) bool
+
+// Patch applies the mutations to the status of obj and writes the changes as a merge patch. The patch only applies to
+// the version of obj it was computed from, so on a conflict obj is fetched again and the mutations are applied to the
+// fresh copy. Nothing is written if none of the mutations changed anything. obj is updated with the written status
+func Patch(ctx context.Context, c client.Client, obj controllerv1alpha1.ControllerWatchObject, mutations ...Mutation) error {
+	first := true
+	return retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		if !first {
+			if err := c.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+				return err
+			}
+		}
+		first = false
+		base := obj.DeepCopyObject().(client.Object)
+		status := obj.GetStatus()
+		changed := false
+		for _, mutation := range mutations {
+			if mutation(status) {
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+		status.LastUpdated = &metav1.Time{Time: time.Now()}
+		return c.Status().Patch(ctx, obj, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{}))
+	})
+}
+
+// SetControllerInstallationStatus moves the controller to the given installation status
+func SetControllerInstallationStatus(installationStatus controllerv1alpha1.ControllerInstallationStatus) Mutation {
+	return func(status *controllerv1alpha1.ControllerWatchStatus) bool {
+		if status.ControllerInstallationStatus == installationStatus {
+			return false
+		}
+		status.ControllerInstallationStatus = installationStatus
+		return true
+	}
+}
+
+// Trigger sets the controller to Pending because usage of the custom resource source was detected.
+// It does nothing if the controller is already hoisted, so any number of triggers results in a single install
+func Trigger(source controllerv1alpha1.TriggerSource) Mutation {
+	return func(status *controllerv1alpha1.ControllerWatchStatus) bool {
+		if status.ControllerInstallationStatus.Hoisted() {
+			return false
+		}
+		status.ControllerInstallationStatus = controllerv1alpha1.ControllerInstallationStatusPending
+		status.LastTriggered = &metav1.Time{Time: source.Time.Time}
+		status.LastTrigger = &source
+		return true
+	}
+}
+
+// UsageResolution is how often the last seen time of a kind is updated while nothing else about its usage changes,
+// so that a burst of events doesn't write the status every time
+const UsageResolution = time.Minute
+
+// RecordUsage updates the usage of a kind with the custom resources which currently exist. earliest is the oldest
+// creation time of those custom resources, which is used as the first seen time the first time the kind is seen
+func RecordUsage(groupKind schema.GroupKind, count int32, namespaces []string, earliest, now time.Time) Mutation {
+	return func(status *controllerv1alpha1.ControllerWatchStatus) bool {
+		index := slices.IndexFunc(status.Usage, func(usage controllerv1alpha1.KindUsage) bool {
+			return usage.Group == groupKind.Group && usage.Kind == groupKind.Kind
+		})
+		if index < 0 {
+			if count == 0 {
+				return false
+			}
+			status.Usage = append(status.Usage, controllerv1alpha1.KindUsage{Group: groupKind.Group, Kind: groupKind.Kind})
+			index = len(status.Usage) - 1
+		}
+		usage := &status.Usage[index]
+		changed := usage.Count != count || !slices.Equal(usage.Namespaces, namespaces)
+		usage.Count = count
+		usage.Namespaces = slices.Clone(namespaces)
+		if count == 0 {
+			return changed
+		}
+		if usage.FirstSeen == nil {
+			usage.FirstSeen = &metav1.Time{Time: earliest}
+			changed = true
+		}
+		if changed || usage.LastSeen == nil || now.Sub(usage.LastSeen.Time) >= UsageResolution {
+			usage.LastSeen = &metav1.Time{Time: now}
+			changed = true
+		}
+		return changed
+	}
+}
+
+// SetCRDsInstallationStatus moves the CRDs to the given installation status
+func SetCRDsInstallationStatus(installationStatus controllerv1alpha1.CRDInstallationStatus) Mutation {
+	return func(status *controllerv1alpha1.ControllerWatchStatus) bool {
+		if status.CRDsInstallationStatus == installationStatus {
+			return false
+		}
+		status.CRDsInstallationStatus = installationStatus
+		return true
+	}
+}
+
+// SetInstalledCRDs records the kinds of the installed CRDs
+func SetInstalledCRDs(installedCRDs []controllerv1alpha1.GroupVersionKind) Mutation {
+	return func(status *controllerv1alpha1.ControllerWatchStatus) bool {
+		if slices.Equal(status.InstalledCRDs, installedCRDs) {
+			return false
+		}
+		status.InstalledCRDs = slices.Clone(installedCRDs)
+		return true
+	}
+}
+
+// SetObservedGeneration records the generation of the spec which the CRDs were installed from
+func SetObservedGeneration(generation int64) Mutation {
+	return func(status *controllerv1alpha1.ControllerWatchStatus) bool {
+		if status.ObservedGeneration == generation {
+			return false
+		}
+		status.ObservedGeneration = generation
+		return true
+	}
+}
+
+// SetCondition adds or updates a condition
+func SetCondition(condition metav1.Condition) Mutation {
+	return func(status *controllerv1alpha1.ControllerWatchStatus) bool {
+		return meta.SetStatusCondition(&status.Conditions, condition)
+	}
+}
+
+// SetInstallProgress replaces the progress of the current install
+func SetInstallProgress(progress *controllerv1alpha1.InstallProgress) Mutation {
+	return func(status *controllerv1alpha1.ControllerWatchStatus) bool {
+		if equalProgress(status.InstallProgress, progress) {
+			return false
+		}
+		status.InstallProgress = progress.DeepCopy()
+		return true
+	}
+}
+
+// SetStorageVersionMigration adds or replaces the migration status of a CRD
+func SetStorageVersionMigration(migration controllerv1alpha1.StorageVersionMigrationStatus) Mutation {
+	return func(status *controllerv1alpha1.ControllerWatchStatus) bool {
+		for i, existing := range status.StorageVersionMigrations {
+			if existing.CRD == migration.CRD {
+				if existing == migration {
+					return false
+				}
+				status.StorageVersionMigrations[i] = migration
+				return true
+			}
+		}
+		status.StorageVersionMigrations = append(status.StorageVersionMigrations, migration)
+		return true
+	}
+}
+
+// SetResourceRequests records the resource requests of the workloads of the controller
+func SetResourceRequests(requests corev1.ResourceList) Mutation {
+	return func(status *controllerv1alpha1.ControllerWatchStatus) bool {
+		if equality.Semantic.DeepEqual(status.ResourceRequests, requests) {
+			return false
+		}
+		status.ResourceRequests = requests.DeepCopy()
+		return true
+	}
+}
+
+// SetPlan replaces the dry run plan
+func SetPlan(plan *controllerv1alpha1.Plan) Mutation {
+	return func(status *controllerv1alpha1.ControllerWatchStatus) bool {
+		status.Plan = plan.DeepCopy()
+		return true
+	}
+}
+
+func equalProgress(a, b *controllerv1alpha1.InstallProgress) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Generation == b.Generation && a.State == b.State && a.Message == b.Message && a.Logs == b.Logs &&
+		equalTime(&a.QueuedAt, &b.QueuedAt) && equalTime(a.StartedAt, b.StartedAt) && equalTime(a.FinishedAt, b.FinishedAt)
+}
+
+// equalTime compares times at the precision they are stored with, so that a time read back from the API server is
+// equal to the one which was written
+func equalTime(a, b *metav1.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Rfc3339Copy().Time.Equal(b.Rfc3339Copy().Time)
+}
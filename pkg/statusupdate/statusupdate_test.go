@@ -0,0 +1,117 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusupdate
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+)
+
+var _ = Describe("Patch", func() {
+	var kclient client.Client
+	ctx := context.Background()
+	key := client.ObjectKey{Name: "example"}
+
+	get := func() *controllerv1alpha1.ControllerWatch {
+		controllerWatch := &controllerv1alpha1.ControllerWatch{}
+		Expect(kclient.Get(ctx, key, controllerWatch)).To(Succeed())
+		return controllerWatch
+	}
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(controllerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		controllerWatch := &controllerv1alpha1.ControllerWatch{ObjectMeta: metav1.ObjectMeta{Name: key.Name}}
+		kclient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(controllerWatch).WithStatusSubresource(controllerWatch).Build()
+	})
+
+	It("doesn't write anything when nothing changed", func() {
+		controllerWatch := get()
+		resourceVersion := controllerWatch.ResourceVersion
+		Expect(Patch(ctx, kclient, controllerWatch, SetControllerInstallationStatus(""))).To(Succeed())
+		Expect(get().ResourceVersion).To(Equal(resourceVersion))
+		Expect(get().Status.LastUpdated).To(BeNil())
+	})
+
+	It("applies the mutations again on a fresh copy after a conflict", func() {
+		stale := get()
+		fresh := get()
+		Expect(Patch(ctx, kclient, fresh, SetCRDsInstallationStatus(controllerv1alpha1.CRDInstallationStatusInstalled))).To(Succeed())
+
+		Expect(Patch(ctx, kclient, stale, SetControllerInstallationStatus(controllerv1alpha1.ControllerInstallationStatusInstalling))).To(Succeed())
+		Expect(stale.Status.CRDsInstallationStatus).To(Equal(controllerv1alpha1.CRDInstallationStatusInstalled))
+		status := get().Status
+		Expect(status.CRDsInstallationStatus).To(Equal(controllerv1alpha1.CRDInstallationStatusInstalled))
+		Expect(status.ControllerInstallationStatus).To(Equal(controllerv1alpha1.ControllerInstallationStatusInstalling))
+	})
+
+	It("only triggers a controller which isn't hoisted yet", func() {
+		source := func(t time.Time) controllerv1alpha1.TriggerSource {
+			return controllerv1alpha1.TriggerSource{Name: "cr", Time: metav1.NewTime(t)}
+		}
+		first := time.Now().Add(-time.Minute)
+		Expect(Patch(ctx, kclient, get(), Trigger(source(first)))).To(Succeed())
+		status := get().Status
+		Expect(status.ControllerInstallationStatus).To(Equal(controllerv1alpha1.ControllerInstallationStatusPending))
+		Expect(status.LastTriggered.Unix()).To(Equal(first.Unix()))
+		Expect(status.LastTrigger.Name).To(Equal("cr"))
+		resourceVersion := get().ResourceVersion
+
+		// A burst of triggers from stale copies neither conflicts nor writes again
+		stale := get()
+		for range 3 {
+			Expect(Patch(ctx, kclient, stale.DeepCopy(), Trigger(source(time.Now())))).To(Succeed())
+		}
+		Expect(get().ResourceVersion).To(Equal(resourceVersion))
+		Expect(get().Status.LastTriggered.Unix()).To(Equal(first.Unix()))
+	})
+
+	It("records the usage of a kind", func() {
+		kind := schema.GroupKind{Group: "example.com", Kind: "Example"}
+		created := time.Now().Add(-time.Hour)
+		now := time.Now()
+		Expect(Patch(ctx, kclient, get(), RecordUsage(kind, 2, []string{"a", "b"}, created, now))).To(Succeed())
+		usage := get().Status.Usage
+		Expect(usage).To(HaveLen(1))
+		Expect(usage[0].Count).To(Equal(int32(2)))
+		Expect(usage[0].Namespaces).To(Equal([]string{"a", "b"}))
+		Expect(usage[0].FirstSeen.Unix()).To(Equal(created.Unix()))
+		Expect(usage[0].LastSeen.Unix()).To(Equal(now.Unix()))
+
+		// Only moving the last seen time a little doesn't write the status
+		resourceVersion := get().ResourceVersion
+		Expect(Patch(ctx, kclient, get(), RecordUsage(kind, 2, []string{"a", "b"}, created, now.Add(time.Second)))).To(Succeed())
+		Expect(get().ResourceVersion).To(Equal(resourceVersion))
+
+		Expect(Patch(ctx, kclient, get(), RecordUsage(kind, 0, nil, time.Time{}, now.Add(2*time.Second)))).To(Succeed())
+		usage = get().Status.Usage
+		Expect(usage[0].Count).To(BeZero())
+		Expect(usage[0].Namespaces).To(BeEmpty())
+		Expect(usage[0].FirstSeen.Unix()).To(Equal(created.Unix()))
+		Expect(usage[0].LastSeen.Unix()).To(Equal(now.Unix()))
+	})
+})
@@ -0,0 +1,193 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/helm"
+	"github.com/cheeseandcereal/kubehoist/pkg/schedule"
+)
+
+const (
+	// Annotations which helm sets on every object of a release, except for hooks
+	helmReleaseNameAnnotation      = "meta.helm.sh/release-name"
+	helmReleaseNamespaceAnnotation = "meta.helm.sh/release-namespace"
+	// helmHookAnnotation is set on hooks of a chart
+	helmHookAnnotation = "helm.sh/hook"
+)
+
+// shouldTrigger evaluates the trigger spec and schedule of a ControllerWatch against a watched custom resource.
+// If the custom resource should not trigger the installation, the reason is returned, and when the decision could
+// change just by waiting (i.e. for a custom resource to be old enough), how long to wait before evaluating it again
+func (g *GenericWatcher) shouldTrigger(ctx context.Context, obj *metav1.PartialObjectMetadata, controllerWatch controllerv1alpha1.ControllerWatchObject) (bool, string, time.Duration, error) {
+	spec := controllerWatch.GetSpec()
+	// Like in the ControllerWatch reconciler, which reports it, an invalid schedule is ignored
+	state, nextBoundary, err := schedule.Evaluate(spec.Schedule, time.Now())
+	if err == nil && state == schedule.StateHibernate {
+		// Custom resources which were used during the window trigger the installation once it ends
+		return false, "controller is in a hibernate window", time.Until(nextBoundary), nil
+	}
+	if !spec.Trigger.Kinds.Selects(g.gvk()) {
+		return false, "kind is not selected by the trigger kinds", 0, nil
+	}
+	e := &triggerEvaluator{watcher: g, spec: spec, namespaces: map[string]*corev1.Namespace{}}
+	counts, reason, wait, err := e.counts(ctx, obj)
+	if !counts || err != nil {
+		return false, reason, wait, err
+	}
+	if spec.Trigger.MinCount <= 1 {
+		return true, "", 0, nil
+	}
+
+	count, wait, err := e.count(ctx, controllerWatch.GetStatus().InstalledCRDs, int(spec.Trigger.MinCount))
+	if err != nil {
+		return false, "", 0, err
+	}
+	if count < int(spec.Trigger.MinCount) {
+		return false, fmt.Sprintf("only %d of the %d custom resources needed to trigger exist", count, spec.Trigger.MinCount), wait, nil
+	}
+	return true, "", 0, nil
+}
+
+// triggerEvaluator decides which custom resources count towards triggering the installation
+type triggerEvaluator struct {
+	watcher *GenericWatcher
+	spec    *controllerv1alpha1.ControllerWatchSpec
+	// namespaces caches the namespaces which were looked up for the namespace selector
+	namespaces map[string]*corev1.Namespace
+}
+
+// count returns how many custom resources of all trigger kinds count towards triggering the installation, and how
+// long until the next custom resource which is too young counts. Counting stops once minCount custom resources count
+func (e *triggerEvaluator) count(ctx context.Context, installedCRDs []controllerv1alpha1.GroupVersionKind, minCount int) (int, time.Duration, error) {
+	count := 0
+	var wait time.Duration
+	// There is an installed CRD entry per served version, and every version lists the same objects
+	counted := map[schema.GroupKind]bool{}
+	for _, crd := range installedCRDs {
+		groupKind := crd.ToSchemaGVK().GroupKind()
+		if counted[groupKind] || !e.spec.Trigger.Kinds.Selects(crd) {
+			continue
+		}
+		counted[groupKind] = true
+		list := &metav1.PartialObjectMetadataList{}
+		list.SetGroupVersionKind(crd.ToSchemaGVK().GroupVersion().WithKind(crd.Kind + "List"))
+		if err := e.watcher.List(ctx, list, client.InNamespace(e.watcher.Namespace)); err != nil {
+			return 0, 0, fmt.Errorf("failed to list %s: %w", crd, err)
+		}
+		for i := range list.Items {
+			counts, _, itemWait, err := e.counts(ctx, &list.Items[i])
+			if err != nil {
+				return 0, 0, err
+			}
+			if counts {
+				count++
+				if count >= minCount {
+					return count, 0, nil
+				}
+			} else if itemWait > 0 && (wait == 0 || itemWait < wait) {
+				wait = itemWait
+			}
+		}
+	}
+	return count, wait, nil
+}
+
+// counts decides if a single custom resource counts towards triggering the installation
+func (e *triggerEvaluator) counts(ctx context.Context, obj *metav1.PartialObjectMetadata) (bool, string, time.Duration, error) {
+	trigger := e.spec.Trigger
+	if obj.GetAnnotations()[controllerv1alpha1.IgnoreAnnotation] == "true" {
+		return false, "custom resource has the " + controllerv1alpha1.IgnoreAnnotation + " annotation", 0, nil
+	}
+	if trigger.IgnoreDeletes && obj.GetDeletionTimestamp() != nil {
+		return false, "custom resource is being deleted", 0, nil
+	}
+	if trigger.IgnoreReleaseOwned && ownedByRelease(obj, e.spec.HelmControllerSpec) {
+		return false, "custom resource belongs to the helm release of the controller", 0, nil
+	}
+	if trigger.LabelSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(trigger.LabelSelector)
+		if err != nil {
+			return false, "", 0, fmt.Errorf("invalid label selector: %w", err)
+		}
+		if !selector.Matches(labels.Set(obj.GetLabels())) {
+			return false, "custom resource does not match the label selector", 0, nil
+		}
+	}
+	if trigger.NamespaceSelector != nil && obj.GetNamespace() != "" {
+		selector, err := metav1.LabelSelectorAsSelector(trigger.NamespaceSelector)
+		if err != nil {
+			return false, "", 0, fmt.Errorf("invalid namespace selector: %w", err)
+		}
+		namespace, err := e.namespace(ctx, obj.GetNamespace())
+		if err != nil {
+			return false, "", 0, err
+		}
+		if !selector.Matches(labels.Set(namespace.GetLabels())) {
+			return false, "namespace of custom resource does not match the namespace selector", 0, nil
+		}
+	}
+	if trigger.MinAge != nil {
+		if wait := time.Until(obj.GetCreationTimestamp().Add(trigger.MinAge.Duration)); wait > 0 {
+			return false, fmt.Sprintf("custom resource is younger than %s", trigger.MinAge.Duration), wait, nil
+		}
+	}
+	return true, "", 0, nil
+}
+
+func (e *triggerEvaluator) namespace(ctx context.Context, name string) (*corev1.Namespace, error) {
+	if namespace, ok := e.namespaces[name]; ok {
+		return namespace, nil
+	}
+	namespace := &corev1.Namespace{}
+	if err := e.watcher.Get(ctx, client.ObjectKey{Name: name}, namespace); err != nil {
+		return nil, fmt.Errorf("failed to get namespace: %w", err)
+	}
+	e.namespaces[name] = namespace
+	return namespace, nil
+}
+
+// ownedByRelease returns true if the custom resource was created by the helm release of the controller. Objects of a
+// release carry its annotations or the standard labels, while hooks of the chart can only be told apart by helm
+// being their field manager
+func ownedByRelease(obj *metav1.PartialObjectMetadata, helmSpec controllerv1alpha1.HelmInstallSpec) bool {
+	annotations := obj.GetAnnotations()
+	if annotations[helmReleaseNameAnnotation] == helmSpec.ReleaseName && annotations[helmReleaseNamespaceAnnotation] == helmSpec.Namespace {
+		return true
+	}
+	objLabels := obj.GetLabels()
+	if objLabels["app.kubernetes.io/managed-by"] == "Helm" && objLabels["app.kubernetes.io/instance"] == helmSpec.ReleaseName {
+		return true
+	}
+	if _, ok := annotations[helmHookAnnotation]; ok {
+		return slices.ContainsFunc(obj.GetManagedFields(), func(entry metav1.ManagedFieldsEntry) bool {
+			return entry.Manager == helm.FieldManager
+		})
+	}
+	return false
+}
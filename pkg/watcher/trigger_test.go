@@ -0,0 +1,272 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/helm"
+)
+
+var _ = Describe("shouldTrigger", func() {
+	// ConfigMaps stand in for custom resources, so that the fake client knows the kind
+	kind := controllerv1alpha1.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	ctx := context.Background()
+	var controllerWatch *controllerv1alpha1.ControllerWatch
+
+	newObject := func(name string, age time.Duration) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+		}}
+	}
+
+	evaluate := func(event *corev1.ConfigMap, objects ...client.Object) (bool, string, time.Duration) {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		g := &GenericWatcher{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(objects, event)...).Build(),
+			GVK:    kind.ToSchemaGVK(),
+		}
+		obj := &metav1.PartialObjectMetadata{}
+		obj.SetGroupVersionKind(g.GVK)
+		Expect(g.Get(ctx, client.ObjectKeyFromObject(event), obj)).To(Succeed())
+		trigger, reason, wait, err := g.shouldTrigger(ctx, obj, controllerWatch)
+		Expect(err).NotTo(HaveOccurred())
+		return trigger, reason, wait
+	}
+
+	BeforeEach(func() {
+		controllerWatch = &controllerv1alpha1.ControllerWatch{
+			Spec: controllerv1alpha1.ControllerWatchSpec{
+				HelmControllerSpec: controllerv1alpha1.HelmInstallSpec{ReleaseName: "example", Namespace: "example-system"},
+			},
+			Status: controllerv1alpha1.ControllerWatchStatus{InstalledCRDs: []controllerv1alpha1.GroupVersionKind{kind}},
+		}
+	})
+
+	It("evaluates custom resources used in a hibernate window again when it ends", func() {
+		controllerWatch.Spec.Schedule = &controllerv1alpha1.ScheduleSpec{
+			HibernateWindows: []controllerv1alpha1.ScheduleWindow{{Start: "0 0 * * *", Duration: metav1.Duration{Duration: 24 * time.Hour}}},
+		}
+		trigger, reason, wait := evaluate(newObject("night", time.Hour))
+		Expect(trigger).To(BeFalse())
+		Expect(reason).To(ContainSubstring("hibernate window"))
+		Expect(wait).To(BeNumerically(">", 0))
+		Expect(wait).To(BeNumerically("<=", 24*time.Hour))
+	})
+
+	It("ignores an invalid schedule like the ControllerWatch reconciler", func() {
+		controllerWatch.Spec.Schedule = &controllerv1alpha1.ScheduleSpec{
+			HibernateWindows: []controllerv1alpha1.ScheduleWindow{{Start: "every night"}},
+		}
+		trigger, _, _ := evaluate(newObject("example", time.Hour))
+		Expect(trigger).To(BeTrue())
+	})
+
+	It("waits for custom resources to reach the minimum age", func() {
+		controllerWatch.Spec.Trigger.MinAge = &metav1.Duration{Duration: time.Minute}
+		trigger, _, wait := evaluate(newObject("young", 10*time.Second))
+		Expect(trigger).To(BeFalse())
+		Expect(wait).To(BeNumerically("~", 50*time.Second, 5*time.Second))
+
+		trigger, _, _ = evaluate(newObject("old", 2*time.Minute))
+		Expect(trigger).To(BeTrue())
+	})
+
+	It("waits for the minimum count of custom resources which count", func() {
+		controllerWatch.Spec.Trigger.MinCount = 3
+		controllerWatch.Spec.Trigger.MinAge = &metav1.Duration{Duration: time.Minute}
+		ignored := newObject("ignored", time.Hour)
+		ignored.Annotations = map[string]string{controllerv1alpha1.IgnoreAnnotation: "true"}
+
+		trigger, reason, wait := evaluate(newObject("a", time.Hour), newObject("b", time.Hour), newObject("young", 30*time.Second), ignored)
+		Expect(trigger).To(BeFalse())
+		Expect(reason).To(ContainSubstring("only 2 of the 3"))
+		Expect(wait).To(BeNumerically("~", 30*time.Second, 5*time.Second))
+
+		trigger, _, _ = evaluate(newObject("a", time.Hour), newObject("b", time.Hour), newObject("c", time.Hour))
+		Expect(trigger).To(BeTrue())
+	})
+
+	It("stops counting once the minimum count is reached", func() {
+		controllerWatch.Spec.Trigger.MinCount = 2
+		secretKind := controllerv1alpha1.GroupVersionKind{Version: "v1", Kind: "Secret"}
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		var lists atomic.Int32
+		g := &GenericWatcher{
+			Client: fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(newObject("a", time.Hour), newObject("b", time.Hour), newObject("c", time.Hour)).
+				WithInterceptorFuncs(interceptor.Funcs{
+					List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+						lists.Add(1)
+						return c.List(ctx, list, opts...)
+					},
+				}).Build(),
+			GVK: kind.ToSchemaGVK(),
+		}
+		e := &triggerEvaluator{watcher: g, spec: &controllerWatch.Spec, namespaces: map[string]*corev1.Namespace{}}
+		count, _, err := e.count(ctx, []controllerv1alpha1.GroupVersionKind{kind, secretKind}, 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(2))
+		Expect(lists.Load()).To(Equal(int32(1)))
+	})
+
+	It("only counts custom resources matching the label and namespace selectors", func() {
+		controllerWatch.Spec.Trigger.LabelSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}
+		controllerWatch.Spec.Trigger.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}
+		prod := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: map[string]string{"env": "prod"}}}
+		dev := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: map[string]string{"env": "dev"}}}
+		matching := newObject("matching", time.Hour)
+		matching.Labels = map[string]string{"team": "a"}
+		otherTeam := newObject("other-team", time.Hour)
+		otherTeam.Labels = map[string]string{"team": "b"}
+
+		trigger, _, _ := evaluate(matching, prod)
+		Expect(trigger).To(BeTrue())
+
+		trigger, reason, _ := evaluate(otherTeam, prod)
+		Expect(trigger).To(BeFalse())
+		Expect(reason).To(ContainSubstring("label selector"))
+
+		trigger, reason, _ = evaluate(matching, dev)
+		Expect(trigger).To(BeFalse())
+		Expect(reason).To(ContainSubstring("namespace selector"))
+	})
+
+	It("ignores custom resources with the ignore annotation", func() {
+		ignored := newObject("ignored", time.Hour)
+		ignored.Annotations = map[string]string{controllerv1alpha1.IgnoreAnnotation: "true"}
+		trigger, reason, _ := evaluate(ignored)
+		Expect(trigger).To(BeFalse())
+		Expect(reason).To(ContainSubstring(controllerv1alpha1.IgnoreAnnotation))
+
+		ignored.Annotations[controllerv1alpha1.IgnoreAnnotation] = "false"
+		trigger, _, _ = evaluate(ignored)
+		Expect(trigger).To(BeTrue())
+	})
+
+	It("counts the custom resources of a CRD with several versions once", func() {
+		v2 := controllerv1alpha1.GroupVersionKind{Version: "v2", Kind: "ConfigMap"}
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		g := &GenericWatcher{
+			Client: fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(newObject("a", time.Hour), newObject("b", time.Hour)).
+				WithInterceptorFuncs(interceptor.Funcs{
+					// Serve the same ConfigMaps as v2, like the API server does for every version of a CRD
+					List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+						list.GetObjectKind().SetGroupVersionKind(kind.ToSchemaGVK().GroupVersion().WithKind("ConfigMapList"))
+						return c.List(ctx, list, opts...)
+					},
+				}).Build(),
+			GVK: kind.ToSchemaGVK(),
+		}
+		e := &triggerEvaluator{watcher: g, spec: &controllerWatch.Spec, namespaces: map[string]*corev1.Namespace{}}
+		count, _, err := e.count(ctx, []controllerv1alpha1.GroupVersionKind{kind, v2}, 4)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(2))
+	})
+
+	It("only triggers for the selected kinds", func() {
+		otherGroup := controllerv1alpha1.GroupKind{Group: "example.com"}
+		for _, kinds := range []controllerv1alpha1.TriggerKinds{
+			{Exclude: []controllerv1alpha1.GroupKind{{Kind: "ConfigMap"}}},
+			{Include: []controllerv1alpha1.GroupKind{otherGroup}},
+			{Include: []controllerv1alpha1.GroupKind{{}}, Exclude: []controllerv1alpha1.GroupKind{{}}},
+		} {
+			controllerWatch.Spec.Trigger.Kinds = kinds
+			trigger, reason, _ := evaluate(newObject("example", time.Hour))
+			Expect(trigger).To(BeFalse(), "%+v", kinds)
+			Expect(reason).To(ContainSubstring("trigger kinds"))
+		}
+
+		for _, kinds := range []controllerv1alpha1.TriggerKinds{
+			{Include: []controllerv1alpha1.GroupKind{{Kind: "ConfigMap"}, otherGroup}},
+			{Include: []controllerv1alpha1.GroupKind{{}}, Exclude: []controllerv1alpha1.GroupKind{{Group: "example.com", Kind: "ConfigMap"}}},
+		} {
+			controllerWatch.Spec.Trigger.Kinds = kinds
+			trigger, _, _ := evaluate(newObject("example", time.Hour))
+			Expect(trigger).To(BeTrue(), "%+v", kinds)
+		}
+	})
+
+	It("only counts custom resources of the selected kinds", func() {
+		secretKind := controllerv1alpha1.GroupVersionKind{Version: "v1", Kind: "Secret"}
+		controllerWatch.Spec.Trigger.Kinds = controllerv1alpha1.TriggerKinds{Exclude: []controllerv1alpha1.GroupKind{{Kind: "Secret"}}}
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "default"}}
+		g := &GenericWatcher{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(newObject("a", time.Hour), secret).Build(),
+			GVK:    kind.ToSchemaGVK(),
+		}
+		e := &triggerEvaluator{watcher: g, spec: &controllerWatch.Spec, namespaces: map[string]*corev1.Namespace{}}
+		count, _, err := e.count(ctx, []controllerv1alpha1.GroupVersionKind{kind, secretKind}, 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(1))
+	})
+
+	It("ignores custom resources of the helm release of the controller", func() {
+		controllerWatch.Spec.Trigger.IgnoreReleaseOwned = true
+		annotated := newObject("annotated", time.Hour)
+		annotated.Annotations = map[string]string{helmReleaseNameAnnotation: "example", helmReleaseNamespaceAnnotation: "example-system"}
+		labeled := newObject("labeled", time.Hour)
+		labeled.Labels = map[string]string{"app.kubernetes.io/managed-by": "Helm", "app.kubernetes.io/instance": "example"}
+		hook := newObject("hook", time.Hour)
+		hook.Annotations = map[string]string{helmHookAnnotation: "post-install"}
+		hook.ManagedFields = []metav1.ManagedFieldsEntry{{Manager: helm.FieldManager, Operation: metav1.ManagedFieldsOperationUpdate}}
+		otherRelease := newObject("other", time.Hour)
+		otherRelease.Annotations = map[string]string{helmReleaseNameAnnotation: "other", helmReleaseNamespaceAnnotation: "example-system"}
+
+		for _, obj := range []*corev1.ConfigMap{annotated, labeled, hook} {
+			trigger, reason, _ := evaluate(obj)
+			Expect(trigger).To(BeFalse(), obj.Name)
+			Expect(reason).To(ContainSubstring("helm release"))
+		}
+		trigger, _, _ := evaluate(otherRelease)
+		Expect(trigger).To(BeTrue())
+	})
+
+	It("ignores custom resources which are being deleted if configured", func() {
+		deleting := newObject("deleting", time.Hour)
+		deleting.Finalizers = []string{"example.com/cleanup"}
+		deleting.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+		trigger, _, _ := evaluate(deleting)
+		Expect(trigger).To(BeTrue())
+
+		controllerWatch.Spec.Trigger.IgnoreDeletes = true
+		trigger, reason, _ := evaluate(deleting)
+		Expect(trigger).To(BeFalse())
+		Expect(reason).To(ContainSubstring("being deleted"))
+	})
+})
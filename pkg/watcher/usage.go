@@ -0,0 +1,83 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/statusupdate"
+)
+
+// recordUsage updates the usage inventory of the watched kind in the status of the ControllerWatch. The custom
+// resources are listed from the cache which the watch already fills, so this doesn't hit the API server
+func (g *GenericWatcher) recordUsage(ctx context.Context, controllerWatch controllerv1alpha1.ControllerWatchObject) error {
+	list := &metav1.PartialObjectMetadataList{}
+	list.SetGroupVersionKind(g.GVK.GroupVersion().WithKind(g.GVK.Kind + "List"))
+	if err := g.List(ctx, list, client.InNamespace(g.Namespace)); err != nil {
+		return fmt.Errorf("failed to list %s: %w", g.GVK, err)
+	}
+	now := time.Now()
+	earliest := now
+	namespaces := []string{}
+	for _, obj := range list.Items {
+		if created := obj.GetCreationTimestamp().Time; !created.IsZero() && created.Before(earliest) {
+			earliest = created
+		}
+		if obj.GetNamespace() != "" && !slices.Contains(namespaces, obj.GetNamespace()) {
+			namespaces = append(namespaces, obj.GetNamespace())
+		}
+	}
+	slices.Sort(namespaces)
+	return statusupdate.Patch(ctx, g.Client, controllerWatch, statusupdate.RecordUsage(g.GVK.GroupKind(), int32(len(list.Items)), namespaces, earliest, now))
+}
+
+// triggerSource describes the custom resource which triggers the installation
+func (g *GenericWatcher) triggerSource(obj *metav1.PartialObjectMetadata) controllerv1alpha1.TriggerSource {
+	return controllerv1alpha1.TriggerSource{
+		GroupVersionKind: g.gvk(),
+		Namespace:        obj.GetNamespace(),
+		Name:             obj.GetName(),
+		Creator:          creator(obj),
+		Time:             metav1.Now(),
+	}
+}
+
+func (g *GenericWatcher) gvk() controllerv1alpha1.GroupVersionKind {
+	return controllerv1alpha1.GroupVersionKind{Group: g.GVK.Group, Version: g.GVK.Version, Kind: g.GVK.Kind}
+}
+
+// creator returns the field manager which created the object, which is the manager of its oldest managed fields entry
+func creator(obj *metav1.PartialObjectMetadata) string {
+	managedFields := obj.GetManagedFields()
+	if len(managedFields) == 0 {
+		return ""
+	}
+	oldest := slices.MinFunc(managedFields, func(a, b metav1.ManagedFieldsEntry) int {
+		if a.Time == nil || b.Time == nil {
+			return 0
+		}
+		return a.Time.Compare(b.Time.Time)
+	})
+	return oldest.Manager
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+)
+
+var _ = Describe("usage", func() {
+	It("records the usage of the watched kind", func() {
+		ctx := context.Background()
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(controllerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		created := time.Now().Add(-time.Hour).Truncate(time.Second)
+		controllerWatch := &controllerv1alpha1.ControllerWatch{ObjectMeta: metav1.ObjectMeta{Name: "example"}}
+		objects := []client.Object{controllerWatch}
+		for i, namespace := range []string{"b", "a", "b"} {
+			objects = append(objects, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name:              fmt.Sprintf("example-%d", i),
+				Namespace:         namespace,
+				CreationTimestamp: metav1.NewTime(created),
+			}})
+		}
+		g := &GenericWatcher{
+			Client:          fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).WithStatusSubresource(controllerWatch).Build(),
+			GVK:             corev1.SchemeGroupVersion.WithKind("ConfigMap"),
+			ControllerWatch: client.ObjectKeyFromObject(controllerWatch),
+		}
+		Expect(g.Get(ctx, g.ControllerWatch, controllerWatch)).To(Succeed())
+
+		Expect(g.recordUsage(ctx, controllerWatch)).To(Succeed())
+		usage := controllerWatch.Status.Usage
+		Expect(usage).To(HaveLen(1))
+		Expect(usage[0].Kind).To(Equal("ConfigMap"))
+		Expect(usage[0].Count).To(Equal(int32(3)))
+		Expect(usage[0].Namespaces).To(Equal([]string{"a", "b"}))
+		Expect(usage[0].FirstSeen.Time).To(BeTemporally("==", created))
+	})
+
+	It("records the usage of a kind once for all of its versions", func() {
+		ctx := context.Background()
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(controllerv1alpha1.AddToScheme(scheme)).To(Succeed())
+		controllerWatch := &controllerv1alpha1.ControllerWatch{ObjectMeta: metav1.ObjectMeta{Name: "example"}}
+		configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"}}
+		kclient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(controllerWatch, configMap).WithStatusSubresource(controllerWatch).
+			WithInterceptorFuncs(interceptor.Funcs{
+				// Serve the same ConfigMaps as v2, like the API server does for every version of a CRD
+				List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+					list.GetObjectKind().SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("ConfigMapList"))
+					return c.List(ctx, list, opts...)
+				},
+			}).Build()
+		watcher := func(version string) *GenericWatcher {
+			return &GenericWatcher{
+				Client:          kclient,
+				GVK:             schema.GroupVersionKind{Version: version, Kind: "ConfigMap"},
+				ControllerWatch: client.ObjectKeyFromObject(controllerWatch),
+			}
+		}
+		v1, v2 := watcher("v1"), watcher("v2")
+
+		Expect(kclient.Get(ctx, v1.ControllerWatch, controllerWatch)).To(Succeed())
+		Expect(v1.recordUsage(ctx, controllerWatch)).To(Succeed())
+		resourceVersion := controllerWatch.ResourceVersion
+		Expect(v2.recordUsage(ctx, controllerWatch)).To(Succeed())
+		Expect(v1.recordUsage(ctx, controllerWatch)).To(Succeed())
+		Expect(controllerWatch.ResourceVersion).To(Equal(resourceVersion))
+		Expect(controllerWatch.Status.Usage).To(HaveLen(1))
+		Expect(controllerWatch.Status.Usage[0].Count).To(Equal(int32(1)))
+	})
+
+	It("returns the field manager which created an object", func() {
+		older := metav1.NewTime(time.Now().Add(-time.Hour))
+		newer := metav1.Now()
+		obj := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{ManagedFields: []metav1.ManagedFieldsEntry{
+			{Manager: "kubectl-edit", Time: &newer},
+			{Manager: "kubectl-create", Time: &older},
+		}}}
+		Expect(creator(obj)).To(Equal("kubectl-create"))
+		Expect(creator(&metav1.PartialObjectMetadata{})).To(BeEmpty())
+	})
+})
@@ -18,51 +18,74 @@ package watcher
 
 import (
 	"context"
-	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	controllerv1alpha1 "github.com/cheeseandcereal/kubehoist/api/v1alpha1"
+	"github.com/cheeseandcereal/kubehoist/pkg/statusupdate"
 )
 
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
 // GenericWatcher watches an arbitrary resource
 type GenericWatcher struct {
 	client.Client
 	GVK             schema.GroupVersionKind
 	ControllerWatch client.ObjectKey
+	// Namespace restricts this watcher to custom resources in a single namespace.
+	// It is set when the watcher belongs to a NamespacedControllerWatch
+	Namespace string
 }
 
 func (g *GenericWatcher) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
+	// Get the corresponding controller watch
+	controllerWatch := g.newControllerWatch()
+	if err := g.Get(ctx, g.ControllerWatch, controllerWatch); err != nil {
+		log.Error(err, "unable to fetch corresponding ControllerWatch resource for custom resource", "gvk", g.GVK, "ControllerWatch", g.ControllerWatch)
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// Every event, including deletes, changes the usage of the kind
+	if err := g.recordUsage(ctx, controllerWatch); err != nil {
+		log.Error(err, "could not record usage of custom resources", "gvk", g.GVK, "ControllerWatch", g.ControllerWatch)
+		return ctrl.Result{}, err
+	}
+
 	// Fetch the partial arbitray resource
 	obj := &metav1.PartialObjectMetadata{}
 	obj.SetGroupVersionKind(g.GVK)
 	if err := g.Get(ctx, req.NamespacedName, obj); err != nil {
-		log.Error(err, "ignoring not found error for custom resource")
+		log.V(1).Info("ignoring custom resource which no longer exists", "gvk", g.GVK, "name", req.NamespacedName)
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
-	// Get the corresponding controller watch
-	controllerWatch := &controllerv1alpha1.ControllerWatch{}
-	if err := g.Get(ctx, g.ControllerWatch, controllerWatch); err != nil {
-		log.Error(err, "unable to fetch corresponding ControllerWatch resource for custom resource", "gvk", g.GVK, "ControllerWatch", g.ControllerWatch)
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+
+	trigger, reason, wait, err := g.shouldTrigger(ctx, obj, controllerWatch)
+	if err != nil {
+		log.Error(err, "could not evaluate trigger for custom resource", "gvk", g.GVK, "ControllerWatch", g.ControllerWatch)
+		return ctrl.Result{}, err
+	}
+	if !trigger {
+		log.V(1).Info("ignoring watched custom resource", "gvk", g.GVK, "ControllerWatch", g.ControllerWatch, "reason", reason)
+		// Custom resources are evaluated again once waiting changes the decision, i.e. when they are old enough to count or
+		// a hibernate window ends
+		return ctrl.Result{RequeueAfter: wait}, nil
 	}
 
 	log.Info("usage of watched custom resource detected", "gvk", g.GVK, "ControllerWatch", g.ControllerWatch)
 
-	if controllerWatch.Status.ControllerInstallationStatus != controllerv1alpha1.ControllerInstallationStatusInstalled &&
-		controllerWatch.Status.ControllerInstallationStatus != controllerv1alpha1.ControllerInstallationStatusPending {
-		// Set the controller watch status to pending to trigger the installation
+	if !controllerWatch.GetStatus().ControllerInstallationStatus.Hoisted() {
+		// Set the controller watch status to pending to trigger the installation. Triggering is idempotent, so a burst
+		// of custom resources only installs the controller once
 		log.Info("updating controller watch controller installation status to pending", "ControllerWatch", g.ControllerWatch)
-		controllerWatch.Status.ControllerInstallationStatus = controllerv1alpha1.ControllerInstallationStatusPending
-		controllerWatch.Status.LastUpdated = &metav1.Time{Time: time.Now()}
-		if err := g.Status().Update(ctx, controllerWatch); err != nil {
+		if err := statusupdate.Patch(ctx, g.Client, controllerWatch, statusupdate.Trigger(g.triggerSource(obj))); err != nil {
 			log.Error(err, "could not update ControllerWatch status")
 			return ctrl.Result{}, err
 		}
@@ -75,8 +98,23 @@ func (g *GenericWatcher) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 func (g *GenericWatcher) SetupWithManager(mgr ctrl.Manager) error {
 	obj := &metav1.PartialObjectMetadata{}
 	obj.SetGroupVersionKind(g.GVK)
+	name := g.GVK.String()
+	if g.Namespace != "" {
+		name = g.Namespace + "/" + name
+	}
 	return ctrl.NewControllerManagedBy(mgr).
-		WatchesMetadata(obj, &handler.EnqueueRequestForObject{}).
-		Named(g.GVK.String()).
+		WatchesMetadata(obj, &handler.EnqueueRequestForObject{}, builder.WithPredicates(predicate.NewPredicateFuncs(g.inNamespace))).
+		Named(name).
 		Complete(g)
 }
+
+func (g *GenericWatcher) inNamespace(obj client.Object) bool {
+	return g.Namespace == "" || obj.GetNamespace() == g.Namespace
+}
+
+func (g *GenericWatcher) newControllerWatch() controllerv1alpha1.ControllerWatchObject {
+	if g.Namespace != "" {
+		return &controllerv1alpha1.NamespacedControllerWatch{}
+	}
+	return &controllerv1alpha1.ControllerWatch{}
+}
@@ -22,6 +22,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -261,6 +262,109 @@ var _ = Describe("Manager", Ordered, func() {
 			))
 		})
 
+		It("should finish an install after the leader is killed in the middle of it", func() {
+			const controllerWatchName = "e2e-failover"
+			const releaseNamespace = "e2e-failover"
+			const releaseServiceAccount = "e2e-failover-installer"
+
+			By("running two replicas of the controller-manager")
+			cmd := exec.Command("kubectl", "scale", "deployment", "kubehoist-controller-manager",
+				"--replicas=2", "-n", namespace)
+			_, err := utils.Run(cmd)
+			Expect(err).NotTo(HaveOccurred(), "Failed to scale the controller-manager")
+			DeferCleanup(func() {
+				cmd := exec.Command("kubectl", "scale", "deployment", "kubehoist-controller-manager",
+					"--replicas=1", "-n", namespace)
+				_, _ = utils.Run(cmd)
+			})
+			Eventually(func(g Gomega) {
+				cmd := exec.Command("kubectl", "get", "deployment", "kubehoist-controller-manager",
+					"-o", "jsonpath={.status.readyReplicas}", "-n", namespace)
+				output, err := utils.Run(cmd)
+				g.Expect(err).NotTo(HaveOccurred())
+				g.Expect(output).To(Equal("2"))
+			}).Should(Succeed())
+
+			By("creating a service account which the chart is installed as")
+			cmd = exec.Command("kubectl", "create", "ns", releaseNamespace)
+			_, err = utils.Run(cmd)
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(func() {
+				cmd := exec.Command("kubectl", "delete", "ns", releaseNamespace, "--wait=false")
+				_, _ = utils.Run(cmd)
+			})
+			cmd = exec.Command("kubectl", "create", "serviceaccount", releaseServiceAccount, "-n", releaseNamespace)
+			_, err = utils.Run(cmd)
+			Expect(err).NotTo(HaveOccurred())
+			cmd = exec.Command("kubectl", "create", "clusterrolebinding", releaseServiceAccount,
+				"--clusterrole=cluster-admin",
+				fmt.Sprintf("--serviceaccount=%s:%s", releaseNamespace, releaseServiceAccount))
+			_, err = utils.Run(cmd)
+			Expect(err).NotTo(HaveOccurred())
+			DeferCleanup(func() {
+				cmd := exec.Command("kubectl", "delete", "clusterrolebinding", releaseServiceAccount)
+				_, _ = utils.Run(cmd)
+			})
+
+			By("hoisting a ControllerWatch")
+			manifest := fmt.Sprintf(`apiVersion: controller.kubehoist.io/v1alpha1
+kind: ControllerWatch
+metadata:
+  name: %[1]s
+  annotations:
+    kubehoist.io/hoist: now
+spec:
+  serviceAccountName: %[2]s
+  helmSpec:
+    chart: oci://registry-1.docker.io/bitnamicharts/cert-manager
+    namespace: %[3]s
+    releaseName: %[1]s
+    values: |
+      installCRDs: true
+`, controllerWatchName, releaseServiceAccount, releaseNamespace)
+			manifestFile := filepath.Join("/tmp", controllerWatchName+".yaml")
+			Expect(os.WriteFile(manifestFile, []byte(manifest), os.FileMode(0o644))).To(Succeed())
+			cmd = exec.Command("kubectl", "apply", "-f", manifestFile)
+			_, err = utils.Run(cmd)
+			Expect(err).NotTo(HaveOccurred(), "Failed to create ControllerWatch")
+			DeferCleanup(func() {
+				cmd := exec.Command("kubectl", "delete", "controllerwatch", controllerWatchName, "--ignore-not-found")
+				_, _ = utils.Run(cmd)
+			})
+
+			By("waiting for the install to start")
+			installationStatus := func() (string, error) {
+				cmd := exec.Command("kubectl", "get", "controllerwatch", controllerWatchName,
+					"-o", "jsonpath={.status.controllerInstallationStatus}")
+				return utils.Run(cmd)
+			}
+			Eventually(installationStatus).Should(Equal("Installing"))
+
+			By("killing the leader")
+			leader := leaderPodName()
+			cmd = exec.Command("kubectl", "delete", "pod", leader, "-n", namespace, "--grace-period=0", "--force")
+			_, err = utils.Run(cmd)
+			Expect(err).NotTo(HaveOccurred(), "Failed to kill the leader")
+
+			By("waiting for another replica to take over")
+			Eventually(func(g Gomega) {
+				newLeader := leaderPodName()
+				g.Expect(newLeader).NotTo(BeEmpty())
+				g.Expect(newLeader).NotTo(Equal(leader))
+				controllerPodName = newLeader
+			}).Should(Succeed())
+
+			By("validating that the install finishes on the new leader")
+			Eventually(installationStatus, 10*time.Minute).Should(Equal("Installed"))
+			cmd = exec.Command("kubectl", "get", "secrets", "-n", releaseNamespace,
+				"-l", fmt.Sprintf("owner=helm,name=%s", controllerWatchName),
+				"-o", "jsonpath={.items[*].metadata.labels.status}")
+			output, err := utils.Run(cmd)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(output).To(ContainSubstring("deployed"))
+			Expect(output).NotTo(ContainSubstring("pending"), "Release was left pending")
+		})
+
 		// +kubebuilder:scaffold:e2e-webhooks-checks
 
 		// TODO: Customize the e2e test suite with scenarios specific to your project.
@@ -315,6 +419,17 @@ func serviceAccountToken() (string, error) {
 	return out, err
 }
 
+// leaderPodName returns the name of the controller-manager pod which holds the leader election lease
+func leaderPodName() string {
+	cmd := exec.Command("kubectl", "get", "lease", "7a639403.kubehoist.io",
+		"-o", "jsonpath={.spec.holderIdentity}", "-n", namespace)
+	output, err := utils.Run(cmd)
+	Expect(err).NotTo(HaveOccurred(), "Failed to get the leader election lease")
+	// The identity is the hostname of the pod followed by a unique suffix
+	name, _, _ := strings.Cut(output, "_")
+	return name
+}
+
 // getMetricsOutput retrieves and returns the logs from the curl pod used to access the metrics endpoint.
 func getMetricsOutput() string {
 	By("getting the curl-metrics logs")